@@ -18,15 +18,54 @@ type File struct {
 	Language string
 	Name     string
 	Content  []byte
+
+	// Lines, if non-nil, restricts formatting to these lines, for
+	// formatters that support it.
+	Lines []LineRange
+
+	// Mode is the git file mode, eg. 0100644, or 0 if unknown.
+	Mode int
+
+	// Base is the content in the base revision, for languages
+	// that compare against it (see FormatterConfig.BaseContent).
+	// It is nil for added files.
+	Base []byte
+
+	// Support marks a configuration file, eg. .editorconfig, that
+	// is not checked itself. See FormatterConfig.SupportFiles.
+	Support bool
 }
 
 type FormatRequest struct {
 	Files []File
+
+	// Config holds the repository settings. It may be nil.
+	Config *Config
+}
+
+// Finding is a single problem found in a file.
+type Finding struct {
+	// Line is 1-based. It is 0 if the finding applies to the
+	// whole file.
+	Line    int
+	Message string
+
+	// Stage is the pipeline stage that produced the finding, if
+	// the language has several.
+	Stage string
+
+	// Severity is SeverityError, SeverityWarning or SeverityInfo.
+	// If empty, the finding is an error.
+	Severity string
 }
 
 type FormattedFile struct {
 	File
 	Message string
+
+	// Findings lists problems that are not expressed as a
+	// content difference.
+	Findings []Finding
 }
 
 type FormatReply struct {
@@ -0,0 +1,168 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/bazelbuild/buildtools/warn"
+)
+
+// BuildifierConfig configures the lint warnings of the bzl checker.
+// The warnings are listed in
+// https://github.com/bazelbuild/buildtools/blob/master/WARNINGS.md.
+type BuildifierConfig struct {
+	// Enable lists warnings to report in addition to the default
+	// ones, eg. "out-of-order-load", or "all".
+	Enable []string `json:"enable,omitempty" yaml:"enable,omitempty"`
+
+	// Disable lists warnings that are not reported.
+	Disable []string `json:"disable,omitempty" yaml:"disable,omitempty"`
+}
+
+// warnings returns the enabled warnings.
+func (c *BuildifierConfig) warnings() ([]string, error) {
+	enabled := map[string]bool{}
+	for _, w := range warn.DefaultWarnings {
+		enabled[w] = true
+	}
+	if c != nil {
+		known := map[string]bool{}
+		for _, w := range warn.AllWarnings {
+			known[w] = true
+		}
+		for _, w := range c.Enable {
+			switch {
+			case w == "all":
+				for _, a := range warn.AllWarnings {
+					enabled[a] = true
+				}
+			case known[w]:
+				enabled[w] = true
+			default:
+				return nil, fmt.Errorf("enable: unknown warning %q", w)
+			}
+		}
+		for _, w := range c.Disable {
+			if !known[w] {
+				return nil, fmt.Errorf("disable: unknown warning %q", w)
+			}
+			delete(enabled, w)
+		}
+	}
+
+	var out []string
+	for w := range enabled {
+		out = append(out, w)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// parseBzl parses a Starlark file, returning a finding for syntax
+// errors. The library panics on some inputs, so panics are returned
+// as errors.
+func parseBzl(name string, content []byte) (f *build.File, findings []Finding, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: internal error: %v", name, r)
+		}
+	}()
+	f, err = build.Parse(name, content)
+	if pe, ok := err.(build.ParseError); ok {
+		msg := fmt.Sprintf("syntax error at column %d", pe.Pos.LineRune)
+		if pe.Message != "syntax error" {
+			msg += ": " + pe.Message
+		}
+		return nil, []Finding{{Line: pe.Pos.Line, Message: msg}}, nil
+	}
+	return f, nil, err
+}
+
+// buildifierFormatter formats Starlark files in process, like
+// buildifier -mode=fix.
+type buildifierFormatter struct{}
+
+func (b *buildifierFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	var out []FormattedFile
+	for _, f := range in {
+		if f.Support {
+			continue
+		}
+		ff := FormattedFile{File: f}
+		parsed, findings, err := parseBzl(f.Name, f.Content)
+		if err != nil {
+			return nil, err
+		}
+		if parsed != nil {
+			build.Rewrite(parsed, &build.RewriteInfo{})
+			ff.Content = build.Format(parsed)
+		}
+		ff.Findings = findings
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+// buildifierLinter reports buildifier lint warnings.
+type buildifierLinter struct {
+	warnings []string
+}
+
+func (b *buildifierLinter) configure(cfg *Config, lang string) (Formatter, error) {
+	var bc *BuildifierConfig
+	if cfg != nil {
+		bc = cfg.Buildifier
+	}
+	warnings, err := bc.warnings()
+	if err != nil {
+		return nil, err
+	}
+	return &buildifierLinter{warnings: warnings}, nil
+}
+
+func (b *buildifierLinter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	var out []FormattedFile
+	for _, f := range in {
+		if f.Support {
+			continue
+		}
+		ff := FormattedFile{File: f}
+		// Syntax errors are reported by buildifierFormatter.
+		parsed, _, err := parseBzl(f.Name, f.Content)
+		if err != nil {
+			return nil, err
+		}
+		if parsed != nil {
+			pkg := path.Dir(f.Name)
+			if pkg == "." {
+				pkg = ""
+			}
+			for _, w := range warn.FileWarnings(parsed, pkg, b.warnings, false) {
+				ff.Findings = append(ff.Findings, Finding{
+					Line:    w.Start.Line,
+					Message: fmt.Sprintf("%s (%s)", w.Message, w.URL),
+					Stage:   w.Category,
+				})
+			}
+		}
+		out = append(out, ff)
+	}
+	return out, nil
+}
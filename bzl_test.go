@@ -0,0 +1,98 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBzl(t *testing.T) {
+	for _, tc := range []struct {
+		name, in string
+		cfg      *BuildifierConfig
+		want     []string
+	}{
+		{"BUILD", "cc_library(\n    name = \"a\",\n)\n", nil, nil},
+		{"pkg/BUILD.bazel", "cc_library(name='a', deps=[':c', ':b'])\n", nil,
+			[]string{"1: buildifier: found a difference"}},
+		{"BUILD", "load(\":a.bzl\", \"x\")\n\ncc_library(\n    name = \"a\",\n)\n", nil,
+			[]string{"1: load: Loaded symbol \"x\" is unused"}},
+		{"BUILD", "load(\":a.bzl\", \"x\")\n\ncc_library(\n    name = \"a\",\n)\n",
+			&BuildifierConfig{Disable: []string{"load"}}, nil},
+		{"a.bzl", "\"\"\"Doc.\"\"\"\n\ndef f():\n    native.cc_library(name = \"a\")\n", nil, nil},
+		{"BUILD", "native.cc_library(\n    name = \"a\",\n)\n", nil,
+			[]string{"1: native-build: The \"native\" module shouldn't be used in BUILD files"}},
+		{"BUILD", "cc_library(name = )\n", nil, []string{"1: buildifier: syntax error at column 20: syntax error near )"}},
+	} {
+		req := &FormatRequest{
+			Files:  []File{{Language: "bzl", Name: tc.name, Content: []byte(tc.in)}},
+			Config: &Config{Buildifier: tc.cfg},
+		}
+		rep := &FormatReply{}
+		if err := Format(req, rep); err != nil {
+			t.Errorf("%s %q: %v", tc.name, tc.in, err)
+			continue
+		}
+		var got []string
+		for _, f := range rep.Files[0].Findings {
+			got = append(got, fmt.Sprintf("%d: %s: %s", f.Line, f.Stage, f.Message))
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s %q: got %q, want %q", tc.name, tc.in, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if !strings.HasPrefix(got[i], tc.want[i]) {
+				t.Errorf("%s %q: got %q, want %q", tc.name, tc.in, got[i], tc.want[i])
+			}
+		}
+	}
+}
+
+func TestBuildifierConfig(t *testing.T) {
+	for _, tc := range []struct {
+		cfg     *BuildifierConfig
+		has     string
+		hasNot  string
+		wantErr string
+	}{
+		{nil, "load", "out-of-order-load", ""},
+		{&BuildifierConfig{Enable: []string{"out-of-order-load"}}, "out-of-order-load", "", ""},
+		{&BuildifierConfig{Enable: []string{"all"}, Disable: []string{"load"}}, "unsorted-dict-items", "load", ""},
+		{&BuildifierConfig{Enable: []string{"nope"}}, "", "", "unknown warning"},
+		{&BuildifierConfig{Disable: []string{"nope"}}, "", "", "unknown warning"},
+	} {
+		got, err := tc.cfg.warnings()
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%+v: got error %v, want %q", tc.cfg, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: %v", tc.cfg, err)
+			continue
+		}
+		set := map[string]bool{}
+		for _, w := range got {
+			set[w] = true
+		}
+		if !set[tc.has] || tc.hasNot != "" && set[tc.hasNot] {
+			t.Errorf("%+v: got %q, want %q and not %q", tc.cfg, got, tc.has, tc.hasNot)
+		}
+	}
+}
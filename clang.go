@@ -0,0 +1,97 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+)
+
+// ClangFormatConfig configures the languages formatted with
+// clang-format.
+type ClangFormatConfig struct {
+	// Version pins the clang-format version, by running
+	// clang-format-<Version>, eg. "14", which must be installed.
+	// If empty, clang-format is used.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// clangFormatConfigNames are the names of clang-format style files.
+// They are passed to clang-format as support files, so --style=file
+// finds the nearest one as it does locally.
+var clangFormatConfigNames = []string{".clang-format", "_clang-format"}
+
+var clangVersionRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// clangFormatLanguages maps the languages formatted by clang-format
+// to their file name regexp and Gerrit query. The regexps don't
+// overlap: header files (.h) belong to cpp, as clang-format formats
+// them as C++. The proto language also runs clang-format (see
+// registerProto).
+var clangFormatLanguages = map[string]struct {
+	regex, query string
+}{
+	"c":          {`\.c$`, "ext:c"},
+	"cpp":        {`\.(cc|cpp|cxx|c\+\+|h|hh|hpp|hxx|inc)$`, "(ext:cc OR ext:cpp OR ext:cxx OR ext:h OR ext:hh OR ext:hpp OR ext:hxx OR ext:inc)"},
+	"objc":       {`\.(m|mm)$`, "(ext:m OR ext:mm)"},
+	"javascript": {`\.(js|mjs|cjs)$`, "(ext:js OR ext:mjs OR ext:cjs)"},
+}
+
+// registerClangFormat adds the clang-format languages.
+func registerClangFormat(bin string) {
+	for lang, l := range clangFormatLanguages {
+		formatters[lang] = &FormatterConfig{
+			Regex:        regexp.MustCompile(l.regex),
+			Query:        l.query,
+			Formatter:    &clangFormatter{bin: bin},
+			SupportFiles: clangFormatConfigNames,
+		}
+	}
+}
+
+// clangFormatter formats files in place with clang-format, in the
+// style of the nearest .clang-format file.
+type clangFormatter struct {
+	bin  string
+	args []string
+}
+
+func (f *clangFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	out := *f
+	if cfg != nil && cfg.ClangFormat != nil && cfg.ClangFormat.Version != "" {
+		v := cfg.ClangFormat.Version
+		if !clangVersionRegex.MatchString(v) {
+			return nil, fmt.Errorf("clang_format: invalid version %q", v)
+		}
+		bin, err := exec.LookPath("clang-format-" + v)
+		if err != nil {
+			return nil, fmt.Errorf("clang_format: version %s is not installed", v)
+		}
+		out.bin = bin
+	}
+	out.args = append(append([]string{}, f.args...), cfg.Language(lang).Args...)
+	return &out, nil
+}
+
+func (f *clangFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	tf := &toolFormatter{
+		bin:   f.bin,
+		args:  append([]string{"-i", "--style=file"}, f.args...),
+		lines: "--lines=%d:%d",
+	}
+	return tf.Format(in, outSink)
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fakeClangFormat writes a script that replaces each file with the
+// style flag and the .clang-format file of the root directory.
+func fakeClangFormat(t *testing.T, dir, name string) string {
+	bin := filepath.Join(dir, name)
+	script := `#!/bin/sh
+style=
+for a; do
+  case "$a" in
+    --style=*) style="$a" ;;
+    -*) ;;
+    *) { echo "$style"; cat .clang-format; } > "$a" ;;
+  esac
+done
+`
+	if err := ioutil.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestClangFormatter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &clangFormatter{bin: fakeClangFormat(t, dir, "clang-format")}
+	out, err := f.Format([]File{
+		{Name: "src/a.c", Content: []byte("int x;\n")},
+		{Name: ".clang-format", Content: []byte("BasedOnStyle: Google\n"), Support: true},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	if len(out) != 1 || out[0].Name != "src/a.c" {
+		t.Fatalf("got %v, want src/a.c only", out)
+	}
+	if got, want := string(out[0].Content), "--style=file\nBasedOnStyle: Google\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestClangFormatVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pinned := fakeClangFormat(t, dir, "clang-format-14")
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	f := &clangFormatter{bin: "clang-format"}
+	for _, tc := range []struct {
+		version, bin, err string
+	}{
+		{"", "clang-format", ""},
+		{"14", pinned, ""},
+		{"99", "", "not installed"},
+		{"14; rm", "", "invalid version"},
+	} {
+		cfg := &Config{ClangFormat: &ClangFormatConfig{Version: tc.version}}
+		got, err := f.configure(cfg, "c")
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("version %q: got error %v, want %q", tc.version, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("version %q: %v", tc.version, err)
+		} else if bin := got.(*clangFormatter).bin; bin != tc.bin {
+			t.Errorf("version %q: got binary %q, want %q", tc.version, bin, tc.bin)
+		}
+	}
+}
+
+func TestClangFormatLanguages(t *testing.T) {
+	for name, want := range map[string]string{
+		"a.c":     "c",
+		"a.h":     "cpp",
+		"a.cc":    "cpp",
+		"a.m":     "objc",
+		"a.js":    "javascript",
+		"a.proto": "",
+	} {
+		var got []string
+		for lang, l := range clangFormatLanguages {
+			if regexp.MustCompile(l.regex).MatchString(name) {
+				got = append(got, lang)
+			}
+		}
+		if want == "" && len(got) > 0 || want != "" && (len(got) != 1 || got[0] != want) {
+			t.Errorf("%s: got languages %q, want %q", name, got, want)
+		}
+	}
+}
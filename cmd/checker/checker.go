@@ -20,9 +20,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/rpc"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -36,6 +39,41 @@ type gerritChecker struct {
 	server *gerrit.Server
 	delay  time.Duration
 	todo   chan *gerrit.PendingChecksInfo
+
+	// configs holds the configuration by repository name. The
+	// entry for defaultRepoConfig applies to other repositories.
+	configs map[string]*linter.Config
+
+	// projects caches the project configuration from
+	// refs/meta/config.
+	projects projectConfigCache
+}
+
+// defaultRepoConfig is the key of the configuration that applies to
+// repositories without their own entry.
+const defaultRepoConfig = "*"
+
+// loadConfigs reads a JSON file mapping repository names to
+// linter.Config.
+func loadConfigs(filename string) (map[string]*linter.Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]*linter.Config
+	if err := json.Unmarshal(content, &out); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return out, nil
+}
+
+// repoConfig returns the configuration for the given repository. It
+// may return nil.
+func (gc *gerritChecker) repoConfig(repo string) *linter.Config {
+	if cfg, ok := gc.configs[repo]; ok {
+		return cfg
+	}
+	return gc.configs[defaultRepoConfig]
 }
 
 // checkerScheme is the scheme by which we are registered in the Gerrit server.
@@ -78,13 +116,20 @@ func (gc *gerritChecker) PostChecker(repo, language string, update bool) (*gerri
 	if !ok {
 		return nil, fmt.Errorf("no checker for language %q", language)
 	}
+	// Files detected by content can't be found by a query. The
+	// checker outlives any single change, so only the host
+	// configuration is consulted, not the repository's files.
+	query := cfg.Query
+	if len(cfg.Detect) > 0 && (cfg.Shebang || gc.repoConfig(repo).Language(language).DetectContent) {
+		query = ""
+	}
 	in := gerrit.CheckerInput{
 		UUID:        uuid,
 		Name:        language + " formatting",
 		Repository:  repo,
 		Description: "check source code formatting.",
 		Status:      "ENABLED",
-		Query:       cfg.Query,
+		Query:       query,
 	}
 
 	body, err := json.Marshal(&in)
@@ -135,33 +180,156 @@ func NewGerritChecker(server *gerrit.Server, delay time.Duration) (*gerritChecke
 // errIrrelevant is a marker error value used for checks that don't apply for a change.
 var errIrrelevant = errors.New("irrelevant")
 
+// skippedError is returned for checks that are disabled by the
+// commit message.
+type skippedError struct {
+	reason string
+}
+
+func (e *skippedError) Error() string {
+	return fmt.Sprintf("skipped by %s: %s", linter.SkipFooter, e.reason)
+}
+
+// checkResult is the outcome of checkChange.
+type checkResult struct {
+	// errors fail the check, and warnings are findings below the
+	// LanguageConfig.FailOn threshold.
+	errors, warnings []string
+
+	// skipped lists files that were skipped because they are
+	// excluded or generated.
+	skipped []string
+}
+
 // checkChange checks a (change, patchset) for correct formatting in
-// the given language. It returns a list of complaints, or the
-// errIrrelevant error if there is nothing to do.
-func (c *gerritChecker) checkChange(changeID string, psID int, language string) ([]string, error) {
+// the given language. It returns the complaints and skipped files,
+// the errIrrelevant error if there is nothing to do, or a
+// *skippedError if the check was disabled.
+func (c *gerritChecker) checkChange(changeID string, psID int, language string, repoCfg *linter.Config) (*checkResult, error) {
+	if repoCfg == nil {
+		repoCfg = &linter.Config{}
+	}
 	ch, err := c.server.GetChange(changeID, strconv.Itoa(psID))
 	if err != nil {
 		return nil, err
 	}
-	req := linter.FormatRequest{}
+	if msg, ok := ch.Files["/COMMIT_MSG"]; ok && !repoCfg.DisableSkipFooter {
+		if reason, ok := linter.SkipReason(string(msg.Content), language); ok {
+			return nil, &skippedError{reason}
+		}
+	}
+	cfg, ok := linter.GetFormatter(language)
+	if !ok {
+		return nil, fmt.Errorf("language %q not configured", language)
+	}
+	langCfg := repoCfg.Language(language)
+
+	var names, skipped, treeNames []string
 	for n, f := range ch.Files {
-		cfg, ok := linter.GetFormatter(language)
-		if !ok {
-			return nil, fmt.Errorf("language %q not configured", language)
+		if !checkable(f, cfg) || isSupportFile(n, cfg) {
+			continue
 		}
-		if !cfg.Regex.MatchString(n) {
+		if cfg.Excluded(n, langCfg) {
+			skipped = append(skipped, n+" (excluded)")
+		} else if cfg.MatchesContent(n, f.Content, langCfg) {
+			names = append(names, n)
+			if !strings.HasPrefix(n, "/") {
+				treeNames = append(treeNames, n)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var attrFiles map[string][]byte
+	if !langCfg.CheckGenerated && len(treeNames) > 0 {
+		attrFiles, err = c.fetchFiles(changeID, psID, ch,
+			linter.SupportPaths([]string{linter.GitAttributesName}, treeNames))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req := linter.FormatRequest{Config: repoCfg}
+	for _, n := range names {
+		f := ch.Files[n]
+		if !langCfg.CheckGenerated && !strings.HasPrefix(n, "/") &&
+			(linter.IsGeneratedContent(f.Content) || linter.IsGeneratedByAttributes(attrFiles, n)) {
+			skipped = append(skipped, n+" (generated)")
 			continue
 		}
 
+		content := f.Content
+		if cfg.CommitHeader && n == "/COMMIT_MSG" {
+			header, err := c.commitHeader(changeID, psID)
+			if err != nil {
+				return nil, err
+			}
+			content = append([]byte(header), content...)
+		}
+
+		var lines []linter.LineRange
+		if langCfg.ChangedLines && !strings.HasPrefix(n, "/") && f.Status != gerrit.StatusAdded {
+			diff, err := c.server.GetDiff(changeID, strconv.Itoa(psID), n)
+			if err != nil {
+				return nil, err
+			}
+			lines = editedLines(diff)
+		}
+		var base []byte
+		if cfg.BaseContent && !strings.HasPrefix(n, "/") && f.Status != gerrit.StatusAdded {
+			if base, err = c.server.GetBaseContent(changeID, strconv.Itoa(psID), f.BasePath(n)); err != nil {
+				return nil, err
+			}
+			if base == nil {
+				base = []byte{}
+			}
+		}
 		req.Files = append(req.Files,
 			linter.File{
 				Language: language,
 				Name:     n,
-				Content:  f.Content,
+				Content:  content,
+				Lines:    lines,
+				Mode:     f.NewMode,
+				Base:     base,
 			})
 	}
+	// Formatters comparing with the base check deleted files too,
+	// as empty files.
+	if cfg.BaseContent {
+		var deleted []string
+		for n, f := range ch.Deleted {
+			if checkable(f, cfg) && cfg.Matches(n, langCfg) {
+				deleted = append(deleted, n)
+			}
+		}
+		sort.Strings(deleted)
+		for _, n := range deleted {
+			base, err := c.server.GetBaseContent(changeID, strconv.Itoa(psID), n)
+			if err != nil {
+				return nil, err
+			}
+			if base == nil {
+				base = []byte{}
+			}
+			req.Files = append(req.Files, linter.File{
+				Language: language,
+				Name:     n,
+				Content:  []byte{},
+				Base:     base,
+			})
+		}
+	}
+	sort.Strings(skipped)
 	if len(req.Files) == 0 {
-		return nil, errIrrelevant
+		return &checkResult{skipped: skipped}, errIrrelevant
+	}
+	if len(cfg.SupportFiles) > 0 {
+		support, err := c.supportFiles(changeID, psID, ch, cfg, req.Files)
+		if err != nil {
+			return nil, err
+		}
+		req.Files = append(req.Files, support...)
 	}
 
 	rep := linter.FormatReply{}
@@ -173,25 +341,323 @@ func (c *gerritChecker) checkChange(changeID string, psID int, language string)
 		return nil, err
 	}
 
-	var msgs []string
+	if langCfg.BaseAware {
+		if err := c.restrictToEdits(changeID, psID, ch, &req, &rep); err != nil {
+			return nil, err
+		}
+	}
+
+	orig := map[string][]byte{}
+	for _, f := range req.Files {
+		if !f.Support {
+			orig[f.Name] = f.Content
+		}
+	}
+
+	failOn := langCfg.FailOn
+	res := &checkResult{skipped: skipped}
 	for _, f := range rep.Files {
-		orig := ch.Files[f.Name]
-		if orig == nil {
+		content, ok := orig[f.Name]
+		if !ok {
 			return nil, fmt.Errorf("result had unknown file %q", f.Name)
 		}
-		if !bytes.Equal(f.Content, orig.Content) {
+		// Markers only apply to repository files: the author
+		// writes the commit message that the policy checks.
+		var suppressed []linter.LineRange
+		if !repoCfg.DisableMarkers && !strings.HasPrefix(f.Name, "/") {
+			suppressed = linter.SuppressedRanges(content)
+		}
+
+		findings := f.Findings[:0]
+		for _, finding := range f.Findings {
+			if !linter.ContainsLine(suppressed, finding.Line) {
+				findings = append(findings, finding)
+			}
+		}
+		if len(findings) > 0 {
+			for _, finding := range findings {
+				msg := formatFinding(f.Name, &finding)
+				if finding.Fails(failOn) {
+					res.errors = append(res.errors, msg)
+				} else {
+					res.warnings = append(res.warnings, msg)
+				}
+			}
+			log.Printf("%s/%d: file %s: %d findings", changeID, psID, f.Name, len(findings))
+		} else if len(f.Findings) == 0 && differs(content, f.Content, suppressed) {
 			msg := f.Message
 			if msg == "" {
 				msg = "found a difference"
 			}
-			msgs = append(msgs, fmt.Sprintf("%s: %s", f.Name, msg))
+			res.errors = append(res.errors, fmt.Sprintf("%s: %s", f.Name, msg))
 			log.Printf("%s/%d: file %s: %s", changeID, psID, f.Name, f.Message)
 		} else {
 			log.Printf("%s/%d: file %s: OK", changeID, psID, f.Name)
 		}
 	}
 
-	return msgs, nil
+	return res, nil
+}
+
+// isSupportFile returns true if the file configures the formatter.
+func isSupportFile(name string, cfg *linter.FormatterConfig) bool {
+	for _, b := range cfg.SupportFiles {
+		if path.Base(name) == b {
+			return true
+		}
+	}
+	return false
+}
+
+// supportFiles returns the support files of cfg that exist in the
+// patch set for the given files.
+func (c *gerritChecker) supportFiles(changeID string, psID int, ch *gerrit.Change, cfg *linter.FormatterConfig, files []linter.File) ([]linter.File, error) {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+
+	paths := linter.SupportPaths(cfg.SupportFiles, names)
+	contents, err := c.fetchFiles(changeID, psID, ch, paths)
+	if err != nil {
+		return nil, err
+	}
+	var out []linter.File
+	for _, p := range paths {
+		content, ok := contents[p]
+		if !ok {
+			continue
+		}
+		out = append(out, linter.File{
+			Language: files[0].Language,
+			Name:     p,
+			Content:  content,
+			Support:  true,
+		})
+	}
+	return out, nil
+}
+
+// fetchFiles returns the content of the given paths that exist in
+// the patch set, by path.
+func (c *gerritChecker) fetchFiles(changeID string, psID int, ch *gerrit.Change, paths []string) (map[string][]byte, error) {
+	out := map[string][]byte{}
+	for _, p := range paths {
+		if f, ok := ch.Files[p]; ok {
+			out[p] = f.Content
+			continue
+		}
+		content, err := c.server.GetContent(changeID, strconv.Itoa(psID), p)
+		if gerrit.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		out[p] = content
+	}
+	return out, nil
+}
+
+// checkable returns true if the file has text content that can be
+// formatted. Submodules and binary files are skipped, and symlinks
+// unless the formatter asks for them.
+func checkable(f *gerrit.File, cfg *linter.FormatterConfig) bool {
+	return !f.Binary && !f.IsGitlink() && (cfg.Symlinks || !f.IsSymlink())
+}
+
+// editedLines returns the ranges of lines in the new version of a
+// file that differ from the old version. Deletions yield empty
+// ranges.
+func editedLines(diff *gerrit.DiffInfo) []linter.LineRange {
+	out := []linter.LineRange{}
+	line := 1
+	for _, c := range diff.Content {
+		line += len(c.AB) + c.Skip
+		if len(c.A) == 0 && len(c.B) == 0 {
+			continue
+		}
+		out = append(out, linter.LineRange{Start: line, End: line + len(c.B)})
+		line += len(c.B)
+	}
+	return out
+}
+
+// restrictToEdits implements linter.LanguageConfig.BaseAware. For
+// files that the change modifies, and that were not clean in the
+// base revision either, it drops the differences and findings that
+// do not touch the lines edited by the change.
+func (c *gerritChecker) restrictToEdits(changeID string, psID int, ch *gerrit.Change, req *linter.FormatRequest, rep *linter.FormatReply) error {
+	baseReq := linter.FormatRequest{Config: req.Config}
+	for _, f := range req.Files {
+		info := ch.Files[f.Name]
+		if f.Support {
+			baseReq.Files = append(baseReq.Files, f)
+			continue
+		}
+		if strings.HasPrefix(f.Name, "/") || info == nil || info.Status == gerrit.StatusAdded {
+			continue
+		}
+		content, err := c.server.GetBaseContent(changeID, strconv.Itoa(psID), info.BasePath(f.Name))
+		if err != nil {
+			return err
+		}
+		baseReq.Files = append(baseReq.Files, linter.File{
+			Language: f.Language,
+			Name:     f.Name,
+			Content:  content,
+		})
+	}
+	base := map[string][]byte{}
+	for _, f := range baseReq.Files {
+		if !f.Support {
+			base[f.Name] = f.Content
+		}
+	}
+	if len(base) == 0 {
+		return nil
+	}
+
+	// If the base does not format, all of it counts as unclean.
+	baseRep := linter.FormatReply{}
+	if err := linter.Format(&baseReq, &baseRep); err != nil {
+		log.Printf("%s/%d: formatting base: %v", changeID, psID, err)
+	}
+	clean := map[string]bool{}
+	baseFindings := map[string]bool{}
+	for _, f := range baseRep.Files {
+		clean[f.Name] = len(f.Findings) == 0 && bytes.Equal(f.Content, base[f.Name])
+		for _, finding := range f.Findings {
+			baseFindings[f.Name+":"+finding.Message] = true
+		}
+	}
+
+	orig := map[string][]byte{}
+	for _, f := range req.Files {
+		orig[f.Name] = f.Content
+	}
+	for i := range rep.Files {
+		f := &rep.Files[i]
+		baseContent, ok := base[f.Name]
+		if !ok || clean[f.Name] {
+			continue
+		}
+
+		var edited []linter.LineRange
+		for _, h := range linter.Diff(baseContent, orig[f.Name]) {
+			edited = append(edited, h.B)
+		}
+
+		var keep []linter.Hunk
+		for _, h := range linter.Diff(orig[f.Name], f.Content) {
+			if overlapsAny(h.A, edited) {
+				keep = append(keep, h)
+			}
+		}
+		f.Content = linter.ApplyHunks(orig[f.Name], f.Content, keep)
+
+		// Differences are reported at the first line of their
+		// hunk, which need not be edited itself.
+		findings := f.Findings[:0]
+		for _, finding := range f.Findings {
+			if finding.Line == 0 && !baseFindings[f.Name+":"+finding.Message] ||
+				finding.Line > 0 && (linter.ContainsLine(edited, finding.Line) || startsHunk(keep, finding.Line)) {
+				findings = append(findings, finding)
+			}
+		}
+		f.Findings = findings
+		log.Printf("%s/%d: file %s: restricted to %d edited ranges", changeID, psID, f.Name, len(edited))
+	}
+	return nil
+}
+
+// startsHunk returns true if one of the hunks starts at the line.
+func startsHunk(hunks []linter.Hunk, line int) bool {
+	for _, h := range hunks {
+		if h.A.Start == line {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapsAny returns true if r overlaps one of the ranges.
+func overlapsAny(r linter.LineRange, ranges []linter.LineRange) bool {
+	for _, o := range ranges {
+		if r.Overlaps(o) {
+			return true
+		}
+	}
+	return false
+}
+
+// differs returns true if the formatted content differs from the
+// original outside the suppressed ranges.
+func differs(orig, formatted []byte, suppressed []linter.LineRange) bool {
+	if bytes.Equal(orig, formatted) {
+		return false
+	}
+	if len(suppressed) == 0 {
+		return true
+	}
+	return linter.DiffersOutside(orig, formatted, suppressed)
+}
+
+// checkMessages returns the messages and status of a check with the
+// given result and LanguageConfig.Severity. Errors come first, and
+// warnings are marked as such.
+func checkMessages(res *checkResult, severity string) ([]string, status) {
+	errors, warnings := res.errors, res.warnings
+	if severity == linter.SeverityWarning {
+		errors, warnings = nil, append(append([]string{}, errors...), warnings...)
+	}
+	var msgs []string
+	msgs = append(msgs, errors...)
+	if len(warnings) > 0 {
+		msgs = append(msgs, "warning: "+warnings[0])
+		msgs = append(msgs, warnings[1:]...)
+	}
+	if len(errors) > 0 {
+		return msgs, statusFail
+	}
+	return msgs, statusSuccessful
+}
+
+// skippedMessage lists the skipped files for the check message.
+func skippedMessage(skipped []string) string {
+	return "skipped " + strings.Join(skipped, ", ")
+}
+
+// formatFinding formats a finding for the check message.
+func formatFinding(name string, f *linter.Finding) string {
+	msg := f.Message
+	if f.Severity != "" && f.Severity != linter.SeverityError {
+		msg = f.Severity + ": " + msg
+	}
+	if f.Stage != "" {
+		msg = f.Stage + ": " + msg
+	}
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s", name, msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", name, f.Line, msg)
+}
+
+// commitHeader returns the identities of the patch set, formatted
+// with linter.CommitHeader.
+func (c *gerritChecker) commitHeader(changeID string, psID int) (string, error) {
+	rev, err := c.server.GetRevision(changeID, psID)
+	if err != nil {
+		return "", err
+	}
+	if rev.Commit == nil || rev.Commit.Author == nil || rev.Commit.Committer == nil {
+		return "", fmt.Errorf("change %s/%d: commit info missing", changeID, psID)
+	}
+
+	uploader := ""
+	if rev.Uploader != nil && rev.Uploader.Email != "" {
+		uploader = fmt.Sprintf("%s <%s>", rev.Uploader.Name, rev.Uploader.Email)
+	}
+	return linter.CommitHeader(rev.Commit.Author.String(), rev.Commit.Committer.String(), uploader), nil
 }
 
 func (c *gerritChecker) Serve() {
@@ -262,10 +728,106 @@ func (s status) String() string {
 	}[s]
 }
 
+// configError is returned for invalid configuration files.
+type configError struct {
+	source string
+	err    error
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.source, e.err)
+}
+
+// loadConfig returns the configuration for a patch set: the
+// configuration of the repository on the checker host, overridden
+// by the linter.ConfigFileName file at the revision, or if absent,
+// on the target branch, and then by the project configuration,
+// which the change cannot modify. Errors in the files are returned
+// as *configError. Project-wide settings also apply to langs, which
+// may name languages that are not in linter.SupportedLanguages, such
+// as commit footer checks.
+func (gc *gerritChecker) loadConfig(changeID string, psID int, repo string, langs ...string) (*linter.Config, error) {
+	cfg, err := gc.loadRepoConfig(changeID, psID, repo)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := gc.projectConfig(repo)
+	if err != nil {
+		return nil, err
+	}
+	out, err := applyProjectConfig(cfg, pc, langs...)
+	if err != nil {
+		return nil, &configError{fmt.Sprintf("%s of %s", projectConfigFile, repo), err}
+	}
+	return out, nil
+}
+
+// loadRepoConfig returns the host configuration, overridden by the
+// linter.ConfigFileName file. A change that modifies that file is
+// checked with the file of its base revision, so it cannot relax
+// its own checks; its version is only validated.
+func (gc *gerritChecker) loadRepoConfig(changeID string, psID int, repo string) (*linter.Config, error) {
+	hostCfg := gc.repoConfig(repo)
+
+	source := fmt.Sprintf("%s at patch set %d", linter.ConfigFileName, psID)
+	content, err := gc.server.GetContent(changeID, strconv.Itoa(psID), linter.ConfigFileName)
+	if err == nil {
+		repoCfg, err := linter.ParseConfig(content)
+		if err != nil {
+			return nil, &configError{source, err}
+		}
+		baseContent, err := gc.server.GetBaseContent(changeID, strconv.Itoa(psID), linter.ConfigFileName)
+		if gerrit.IsNotFound(err) {
+			return hostCfg.Merge(nil), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(content, baseContent) {
+			source = fmt.Sprintf("%s at the base of patch set %d", linter.ConfigFileName, psID)
+			if repoCfg, err = linter.ParseConfig(baseContent); err != nil {
+				return nil, &configError{source, err}
+			}
+		}
+		return hostCfg.Merge(repoCfg), nil
+	}
+	if gerrit.IsNotFound(err) {
+		var info *gerrit.ChangeInfo
+		info, err = gc.server.GetChangeInfo(changeID)
+		if err != nil {
+			return nil, err
+		}
+		source = fmt.Sprintf("%s on branch %s", linter.ConfigFileName, info.Branch)
+		content, err = gc.server.GetBranchContent(info.Project, info.Branch, linter.ConfigFileName)
+		if gerrit.IsNotFound(err) {
+			return hostCfg.Merge(nil), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	repoCfg, err := linter.ParseConfig(content)
+	if err != nil {
+		return nil, &configError{source, err}
+	}
+	return hostCfg.Merge(repoCfg), nil
+}
+
 // executeCheck executes the pending checks specified in the argument.
 func (gc *gerritChecker) executeCheck(pc *gerrit.PendingChecksInfo) error {
 	changeID := strconv.Itoa(pc.PatchSet.ChangeNumber)
 	psID := pc.PatchSet.PatchSetID
+	var langs []string
+	for uuid := range pc.PendingChecks {
+		if lang, ok := checkerLanguage(uuid); ok {
+			langs = append(langs, lang)
+		}
+	}
+	repoCfg, cfgErr := gc.loadConfig(changeID, psID, pc.PatchSet.Repository, langs...)
+	if _, ok := cfgErr.(*configError); cfgErr != nil && !ok {
+		return cfgErr
+	}
 	for uuid := range pc.PendingChecks {
 		now := gerrit.Timestamp(time.Now())
 		checkInput := gerrit.CheckInput{
@@ -285,18 +847,29 @@ func (gc *gerritChecker) executeCheck(pc *gerrit.PendingChecksInfo) error {
 		if !ok {
 			msg = fmt.Sprintf("uuid %q has unknown language", uuid)
 			status = statusFail
+		} else if cfgErr != nil {
+			msg = cfgErr.Error()
+			status = statusFail
+		} else if severity := repoCfg.Language(lang).Severity; severity == linter.SeverityOff {
+			msg = "disabled by configuration"
+			status = statusIrrelevant
 		} else {
-			msgs, err := gc.checkChange(changeID, psID, lang)
-			if err == errIrrelevant {
+			res, err := gc.checkChange(changeID, psID, lang, repoCfg)
+			var msgs []string
+			if skipped, ok := err.(*skippedError); ok {
+				status = statusIrrelevant
+				msgs = []string{skipped.Error()}
+			} else if err == errIrrelevant {
 				status = statusIrrelevant
 			} else if err != nil {
 				status = statusFail
 				log.Printf("checkChange(%s, %d, %q): %v", changeID, psID, lang, err)
 				msgs = []string{fmt.Sprintf("tool failure: %v", err)}
-			} else if len(msgs) == 0 {
-				status = statusSuccessful
 			} else {
-				status = statusFail
+				msgs, status = checkMessages(res, severity)
+			}
+			if res != nil && len(res.skipped) > 0 && (err == nil || err == errIrrelevant) {
+				msgs = append(msgs, skippedMessage(res.skipped))
 			}
 			msg = strings.Join(msgs, ", ")
 			if len(msg) > 1000 {
@@ -15,13 +15,19 @@
 package main
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	linter "github.com/google/gerrit-linter"
 	"github.com/google/gerrit-linter/gerrit"
 )
 
@@ -35,6 +41,235 @@ func TestSchemeLanguage(t *testing.T) {
 	}
 }
 
+func TestCheckMessages(t *testing.T) {
+	for _, tc := range []struct {
+		res      checkResult
+		severity string
+		want     []string
+		status   status
+	}{
+		{checkResult{}, "", nil, statusSuccessful},
+		{checkResult{errors: []string{"a"}, warnings: []string{"b", "c"}}, "", []string{"a", "warning: b", "c"}, statusFail},
+		{checkResult{warnings: []string{"b"}}, "", []string{"warning: b"}, statusSuccessful},
+		{checkResult{errors: []string{"a"}, warnings: []string{"b"}}, linter.SeverityWarning, []string{"warning: a", "b"}, statusSuccessful},
+	} {
+		got, status := checkMessages(&tc.res, tc.severity)
+		if !reflect.DeepEqual(got, tc.want) || status != tc.status {
+			t.Errorf("checkMessages(%v, %q) = %q, %v, want %q, %v", tc.res, tc.severity, got, status, tc.want, tc.status)
+		}
+	}
+}
+
+func TestEditedLines(t *testing.T) {
+	diff := &gerrit.DiffInfo{
+		Content: []*gerrit.DiffContent{
+			{AB: []string{"a", "b"}},
+			{A: []string{"c"}, B: []string{"C", "C2"}},
+			{Skip: 10},
+			{A: []string{"d"}},
+			{AB: []string{"e"}},
+			{B: []string{"f"}},
+		},
+	}
+	got := editedLines(diff)
+	want := []linter.LineRange{{Start: 3, End: 5}, {Start: 15, End: 15}, {Start: 16, End: 17}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRestrictToEdits(t *testing.T) {
+	const basePath = "/changes/1/revisions/2/files/a.txt/content"
+	gc, ts := newFakeChecker(map[string]string{
+		basePath: base64Content("a \nx\nb\nc\nd\n"),
+	})
+	defer ts.Close()
+
+	ch := &gerrit.Change{Files: map[string]*gerrit.File{"a.txt": {}}}
+	req := linter.FormatRequest{Files: []linter.File{{
+		Language: "whitespace",
+		Name:     "a.txt",
+		Content:  []byte("a \nx\nb\nC\nd\n"),
+	}}}
+	rep := linter.FormatReply{Files: []linter.FormattedFile{{
+		File: linter.File{
+			Name:    "a.txt",
+			Content: []byte("a\nx\nB\nc\nd\n"),
+		},
+		Findings: []linter.Finding{
+			{Line: 1, Message: "found a difference"},
+			{Line: 3, Message: "found a difference"},
+			{Line: 5, Message: "bad d"},
+		},
+	}}}
+	if err := gc.restrictToEdits("1", 2, ch, &req, &rep); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only line 4 is edited, but the hunk changing lines 3 and 4
+	// overlaps it.
+	got := rep.Files[0]
+	if want := "a \nx\nB\nc\nd\n"; string(got.Content) != want {
+		t.Errorf("got content %q, want %q", got.Content, want)
+	}
+	want := []linter.Finding{{Line: 3, Message: "found a difference"}}
+	if !reflect.DeepEqual(got.Findings, want) {
+		t.Errorf("got findings %+v, want %+v", got.Findings, want)
+	}
+}
+
+func TestCommitMessageMarkers(t *testing.T) {
+	msg := strings.Repeat("x", 80) + " gerrit-linter:off\n\nBody.\n"
+	gc, ts := newFakeChecker(map[string]string{
+		"/changes/1/revisions/2/files/":                      ")]}'\n" + `{"/COMMIT_MSG": {}}`,
+		"/changes/1/revisions/2/files/%2FCOMMIT_MSG/content": base64Content(msg),
+	})
+	defer ts.Close()
+
+	res, err := gc.checkChange("1", 2, "commitmsg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.errors) != 1 || !strings.Contains(res.errors[0], "70 chars") {
+		t.Errorf("got errors %q, want subject length error", res.errors)
+	}
+}
+
+func TestDeletedProto(t *testing.T) {
+	gc, ts := newFakeChecker(map[string]string{
+		"/changes/1/revisions/2/files/":                         ")]}'\n" + `{"a.proto": {"status": "D"}, "b.proto": {"status": "D"}}`,
+		"/changes/1/revisions/2/files/a.proto/content?parent=1": base64Content("message A {}\nenum E { E_UNSPECIFIED = 0; }\n"),
+		"/changes/1/revisions/2/files/b.proto/content?parent=1": base64Content(""),
+	})
+	defer ts.Close()
+
+	res, err := gc.checkChange("1", 2, "proto-breaking", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a.proto: MESSAGE_NO_DELETE: message A was removed or renamed",
+		"a.proto: ENUM_NO_DELETE: enum E was removed or renamed",
+	}
+	if !reflect.DeepEqual(res.errors, want) {
+		t.Errorf("got errors %q, want %q", res.errors, want)
+	}
+}
+
+func TestCheckable(t *testing.T) {
+	symlinks := &linter.FormatterConfig{Symlinks: true}
+	for _, tc := range []struct {
+		file gerrit.File
+		want bool
+	}{
+		{gerrit.File{}, true},
+		{gerrit.File{NewMode: gerrit.ModeRegular}, true},
+		{gerrit.File{NewMode: gerrit.ModeExecutable}, true},
+		{gerrit.File{Status: gerrit.StatusRenamed, OldPath: "a", NewMode: gerrit.ModeRegular}, true},
+		{gerrit.File{NewMode: gerrit.ModeSymlink}, false},
+		{gerrit.File{NewMode: gerrit.ModeGitlink}, false},
+		{gerrit.File{Binary: true, NewMode: gerrit.ModeRegular}, false},
+	} {
+		if got := checkable(&tc.file, &linter.FormatterConfig{}); got != tc.want {
+			t.Errorf("checkable(%+v) = %v, want %v", tc.file, got, tc.want)
+		}
+	}
+
+	if !checkable(&gerrit.File{NewMode: gerrit.ModeSymlink}, symlinks) {
+		t.Errorf("symlink not checkable with Symlinks set")
+	}
+}
+
+// newFakeChecker returns a checker for a fake Gerrit server that
+// serves the given responses by escaped path, with the query if
+// there is a response for it, and 404 otherwise.
+func newFakeChecker(responses map[string]string) (*gerritChecker, *httptest.Server) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := responses[r.URL.EscapedPath()+"?"+r.URL.RawQuery]
+		if !ok {
+			content, ok = responses[r.URL.EscapedPath()]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	gc, _ := NewGerritChecker(gerrit.New(urlParse(ts.URL)), 0)
+	return gc, ts
+}
+
+func base64Content(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestLoadConfig(t *testing.T) {
+	const revisionPath = "/changes/1/revisions/2/files/.gerrit-linter.yaml/content"
+	const branchPath = "/projects/my%2Frepo/branches/main/files/.gerrit-linter.yaml/content"
+	changeInfo := `)]}'
+{"project": "my/repo", "branch": "main", "_number": 1}`
+
+	hostCfgs := map[string]*linter.Config{
+		"my/repo": {Languages: map[string]*linter.LanguageConfig{"whitespace": {Args: []string{"--aosp"}}}},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		responses map[string]string
+		want      *linter.LanguageConfig
+		wantErr   string
+	}{
+		{"none", map[string]string{"/changes/1": changeInfo},
+			&linter.LanguageConfig{Args: []string{"--aosp"}}, ""},
+		{"revision", map[string]string{
+			revisionPath: base64Content("languages:\n  whitespace: {severity: warning}\n"),
+			branchPath:   base64Content("languages:\n  whitespace: {severity: off}\n"),
+		}, &linter.LanguageConfig{Args: []string{"--aosp"}, Severity: "warning"}, ""},
+		{"modified", map[string]string{
+			revisionPath:               base64Content("languages:\n  whitespace: {severity: off, args: [--x], exclude: [gen/]}\n"),
+			revisionPath + "?parent=1": base64Content("languages:\n  whitespace: {severity: warning}\n"),
+		}, &linter.LanguageConfig{Args: []string{"--aosp"}, Severity: "warning"}, ""},
+		{"empty base", map[string]string{
+			revisionPath:               base64Content("languages:\n  whitespace: {severity: off, args: [--x]}\n"),
+			revisionPath + "?parent=1": "",
+		}, &linter.LanguageConfig{Args: []string{"--aosp"}}, ""},
+		{"branch", map[string]string{
+			"/changes/1": changeInfo,
+			branchPath:   base64Content("languages:\n  whitespace: {severity: off}\n"),
+		}, &linter.LanguageConfig{Args: []string{"--aosp"}, Severity: "off"}, ""},
+		{"invalid modified", map[string]string{
+			revisionPath:               base64Content("languages: [java]\n"),
+			revisionPath + "?parent=1": base64Content("languages:\n  whitespace: {severity: warning}\n"),
+		}, nil, "invalid .gerrit-linter.yaml at patch set 2"},
+		{"invalid base", map[string]string{
+			revisionPath:               base64Content("languages:\n  whitespace: {severity: warning}\n"),
+			revisionPath + "?parent=1": base64Content("languages: [java]\n"),
+		}, nil, "invalid .gerrit-linter.yaml at the base of patch set 2"},
+		{"invalid", map[string]string{
+			revisionPath: base64Content("languages: [java]\n"),
+		}, nil, "invalid .gerrit-linter.yaml at patch set 2"},
+	} {
+		gc, ts := newFakeChecker(tc.responses)
+		gc.configs = hostCfgs
+		cfg, err := gc.loadConfig("1", 2, "my/repo")
+		ts.Close()
+
+		if tc.wantErr != "" {
+			if _, ok := err.(*configError); !ok || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: got error %v, want %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := cfg.Language("whitespace"); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
 func urlParse(s string) url.URL {
 	u, err := url.Parse(s)
 	if err != nil {
@@ -141,3 +376,91 @@ func TestBasic(t *testing.T) {
 		t.Fatalf("got %q, want %q", info.State, statusSuccessful)
 	}
 }
+
+func TestProjectConfig(t *testing.T) {
+	const changePath = "/changes/1"
+	responses := map[string]string{
+		changePath: `)]}'
+{"project": "my/repo", "branch": "main", "_number": 1}`,
+		"/changes/1/revisions/2/files/.gerrit-linter.yaml/content": base64Content(
+			"commit_message: {max_subject_length: 50, required_footers: [Test]}\n" +
+				"languages:\n  whitespace: {severity: off}\n"),
+		"/projects/my%2Frepo": `)]}'
+{"name": "my/repo", "parent": "All-Projects"}`,
+		"/projects/my%2Frepo/branches/refs%2Fmeta%2Fconfig": `)]}'
+{"ref": "refs/meta/config", "revision": "1111"}`,
+		"/projects/my%2Frepo/branches/refs%2Fmeta%2Fconfig/files/project.config/content": base64Content(
+			"[gerrit-linter]\n  requiredFooter = Bug\n"),
+		"/projects/All-Projects": `)]}'
+{"name": "All-Projects"}`,
+		"/projects/All-Projects/branches/refs%2Fmeta%2Fconfig": `)]}'
+{"ref": "refs/meta/config", "revision": "2222"}`,
+		"/projects/All-Projects/branches/refs%2Fmeta%2Fconfig/files/project.config/content": base64Content(
+			"[gerrit-linter]\n  requiredFooter = Change-Id\n  severity = error\n"),
+	}
+	gc, ts := newFakeChecker(responses)
+	defer ts.Close()
+
+	cfg, err := gc.loadConfig("1", 2, "my/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &linter.CommitMessagePolicy{MaxSubjectLength: 50, RequiredFooters: []string{"Test", "Bug"}}
+	if !reflect.DeepEqual(cfg.CommitMessage, want) {
+		t.Errorf("got %+v, want %+v", cfg.CommitMessage, want)
+	}
+	if got := cfg.Language("whitespace").Severity; got != linter.SeverityError {
+		t.Errorf("got severity %q, want %q", got, linter.SeverityError)
+	}
+
+	// A stale cache entry is used until the meta ref moves.
+	responses["/projects/my%2Frepo/branches/refs%2Fmeta%2Fconfig/files/project.config/content"] =
+		base64Content("[gerrit-linter \"whitespace\"]\n  severity = warning\n")
+	cfg, err = gc.loadConfig("1", 2, "my/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Language("whitespace").Severity; got != linter.SeverityError {
+		t.Errorf("cached: got severity %q, want %q", got, linter.SeverityError)
+	}
+
+	responses["/projects/my%2Frepo/branches/refs%2Fmeta%2Fconfig"] = `)]}'
+{"ref": "refs/meta/config", "revision": "3333"}`
+	cfg, err = gc.loadConfig("1", 2, "my/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Language("whitespace").Severity; got != linter.SeverityWarning {
+		t.Errorf("updated: got severity %q, want %q", got, linter.SeverityWarning)
+	}
+	if got := cfg.CommitMessage.RequiredFooters; !reflect.DeepEqual(got, []string{"Test", "Change-Id"}) {
+		t.Errorf("updated: got footers %q", got)
+	}
+
+	responses["/projects/my%2Frepo/branches/refs%2Fmeta%2Fconfig"] = `)]}'
+{"ref": "refs/meta/config", "revision": "4444"}`
+	responses["/projects/my%2Frepo/branches/refs%2Fmeta%2Fconfig/files/project.config/content"] =
+		base64Content("[gerrit-linter]\n  unknown = 1\n")
+	if _, err := gc.loadConfig("1", 2, "my/repo"); err == nil || !strings.Contains(err.Error(), "project.config of my/repo") {
+		t.Errorf("got %v, want project.config error", err)
+	}
+}
+
+func TestApplyProjectConfigSeverity(t *testing.T) {
+	pc, err := gerrit.ParseGitConfig([]byte("[gerrit-linter]\n  severity = warning\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &linter.Config{Languages: map[string]*linter.LanguageConfig{
+		"commitfooter-bug": {Severity: linter.SeverityOff},
+	}}
+	out, err := applyProjectConfig(cfg, pc, "commitfooter-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range []string{"whitespace", "commitfooter-bug", "commitfooter-test"} {
+		if got := out.Language(lang).Severity; got != linter.SeverityWarning {
+			t.Errorf("%s: got severity %q, want %q", lang, got, linter.SeverityWarning)
+		}
+	}
+}
@@ -37,10 +37,26 @@ func main() {
 	authFile := flag.String("auth_file", "", "file containing user:password")
 	repo := flag.String("repo", "", "the repository (project) name to apply the checker to.")
 	language := flag.String("language", "", "the language that the checker should apply to.")
+	configFile := flag.String("config", "", "JSON file mapping repository names (or \"*\" for the default) to their configuration.")
+	toolsFile := flag.String("tools", "", "JSON file mapping language names to custom formatting tools.")
+	prettier := flag.String("prettier", "", "directory of the prettier package, to enable the prettier language, which replaces the clang-format javascript language.")
+	flag.IntVar(&linter.WorkerPoolSize, "workers", linter.WorkerPoolSize, "maximum number of processes per formatter worker.")
+	flag.IntVar(&linter.WorkerMaxRequests, "worker_requests", linter.WorkerMaxRequests, "number of requests after which a formatter worker is restarted.")
+	flag.DurationVar(&linter.WorkerTimeout, "worker_timeout", linter.WorkerTimeout, "time after which a formatter worker that has not answered a request is killed.")
 	flag.Parse()
 	if *gerritURL == "" {
 		log.Fatal("must set --gerrit")
 	}
+	if *prettier != "" {
+		if err := linter.RegisterPrettier(*prettier); err != nil {
+			log.Fatalf("RegisterPrettier: %v", err)
+		}
+	}
+	if *toolsFile != "" {
+		if err := linter.LoadTools(*toolsFile); err != nil {
+			log.Fatalf("LoadTools: %v", err)
+		}
+	}
 
 	u, err := url.Parse(*gerritURL)
 	if err != nil {
@@ -81,6 +97,12 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *configFile != "" {
+		if gc.configs, err = loadConfigs(*configFile); err != nil {
+			log.Fatalf("loadConfigs: %v", err)
+		}
+	}
+
 	if *list {
 		if out, err := gc.ListCheckers(); err != nil {
 			log.Fatalf("List: %v", err)
@@ -0,0 +1,277 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	linter "github.com/google/gerrit-linter"
+	"github.com/google/gerrit-linter/gerrit"
+)
+
+const (
+	// metaConfigRef is the ref holding project configuration.
+	metaConfigRef = "refs/meta/config"
+
+	// projectConfigFile is the project configuration file in
+	// metaConfigRef.
+	projectConfigFile = "project.config"
+
+	// projectConfigSection is our section in projectConfigFile.
+	projectConfigSection = "gerrit-linter"
+)
+
+// projectConfigEntry is a cached projectConfigFile.
+type projectConfigEntry struct {
+	// revision is the metaConfigRef revision the entry was read
+	// from.
+	revision string
+	parent   string
+	config   gerrit.GitConfig
+}
+
+// projectConfigCache caches projectConfigFile by project.
+type projectConfigCache struct {
+	mu      sync.Mutex
+	entries map[string]*projectConfigEntry
+}
+
+// get returns the projectConfigFile of a project. It is reread if
+// metaConfigRef has moved since it was cached.
+func (c *projectConfigCache) get(server *gerrit.Server, project string) (*projectConfigEntry, error) {
+	branch, err := server.GetBranchInfo(project, metaConfigRef)
+	if gerrit.IsNotFound(err) {
+		return &projectConfigEntry{config: gerrit.GitConfig{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	e := c.entries[project]
+	c.mu.Unlock()
+	if e != nil && e.revision == branch.Revision {
+		return e, nil
+	}
+
+	info, err := server.GetProjectInfo(project)
+	if err != nil {
+		return nil, err
+	}
+	content, err := server.GetBranchContent(project, metaConfigRef, projectConfigFile)
+	if gerrit.IsNotFound(err) {
+		content = nil
+	} else if err != nil {
+		return nil, err
+	}
+	cfg, err := gerrit.ParseGitConfig(content)
+	if err != nil {
+		return nil, &configError{fmt.Sprintf("%s of %s", projectConfigFile, project), err}
+	}
+
+	e = &projectConfigEntry{
+		revision: branch.Revision,
+		parent:   info.Parent,
+		config:   cfg,
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[string]*projectConfigEntry{}
+	}
+	c.entries[project] = e
+	c.mu.Unlock()
+	return e, nil
+}
+
+// projectConfig returns the projectConfigFile of a project, with
+// the settings inherited from its parents up to All-Projects.
+func (gc *gerritChecker) projectConfig(project string) (gerrit.GitConfig, error) {
+	var chain []gerrit.GitConfig
+	seen := map[string]bool{}
+	for p := project; p != "" && !seen[p]; {
+		seen[p] = true
+		e, err := gc.projects.get(gc.server, p)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, e.config)
+		p = e.parent
+	}
+
+	out := gerrit.GitConfig{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		out = chain[i].Inherit(out)
+	}
+	return out, nil
+}
+
+// applyProjectConfig returns cfg with the projectConfigSection
+// settings of a projectConfigFile applied on top. Footers
+// required by the project are added to those of cfg, and other
+// settings replace those of cfg. Settings for all languages apply
+// to the supported languages, those configured in cfg, and extra.
+func applyProjectConfig(cfg *linter.Config, pc gerrit.GitConfig, extra ...string) (*linter.Config, error) {
+	out := &linter.Config{}
+	if cfg != nil {
+		*out = *cfg
+	}
+	out.Languages = map[string]*linter.LanguageConfig{}
+	for lang, lc := range cfg.Merge(nil).Languages {
+		out.Languages[lang] = lc
+	}
+
+	for key, vals := range pc[projectConfigSection] {
+		last := vals[len(vals)-1]
+		var err error
+		switch key {
+		case "severity":
+			all := append(linter.SupportedLanguages(), extra...)
+			for lang := range out.Languages {
+				all = append(all, lang)
+			}
+			for _, lang := range all {
+				lc := *out.Language(lang)
+				lc.Severity = last
+				out.Languages[lang] = &lc
+			}
+		case "requiredfooter":
+			cm := commitMessagePolicy(out)
+			cm.RequiredFooters = appendMissing(cm.RequiredFooters, vals)
+		case "maxsubjectlength":
+			cm := commitMessagePolicy(out)
+			cm.MaxSubjectLength, err = strconv.Atoi(last)
+		case "disableskipfooter":
+			var b bool
+			b, err = parseBool(last)
+			out.DisableSkipFooter = out.DisableSkipFooter || b
+		case "disablemarkers":
+			var b bool
+			b, err = parseBool(last)
+			out.DisableMarkers = out.DisableMarkers || b
+		case "alloweddomain":
+			identityPolicy(out).AllowedDomains = vals
+		case "denieddomain":
+			identityPolicy(out).DeniedDomains = vals
+		case "namepattern":
+			identityPolicy(out).NamePattern = last
+		case "committerisuploader":
+			identityPolicy(out).CommitterIsUploader, err = parseBool(last)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", projectConfigSection, key, err)
+		}
+	}
+
+	// Languages are applied after the section itself, so they
+	// override its severity.
+	langs := pc.Subsections(projectConfigSection)
+	sort.Strings(langs)
+	for _, lang := range langs {
+		lc := *out.Language(lang)
+		for key, vals := range pc[projectConfigSection+"."+lang] {
+			last := vals[len(vals)-1]
+			var err error
+			switch key {
+			case "severity":
+				lc.Severity = last
+			case "failon":
+				lc.FailOn = last
+			case "include":
+				lc.Include = vals
+			case "exclude":
+				lc.Exclude = appendMissing(lc.Exclude, vals)
+			case "arg":
+				lc.Args = vals
+			case "baseaware":
+				lc.BaseAware, err = parseBool(last)
+			case "changedlines":
+				lc.ChangedLines, err = parseBool(last)
+			case "maxlinelength":
+				lc.MaxLineLength, err = strconv.Atoi(last)
+			case "detectcontent":
+				lc.DetectContent, err = parseBool(last)
+			case "checkgenerated":
+				lc.CheckGenerated, err = parseBool(last)
+			default:
+				err = fmt.Errorf("unknown key")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s.%s: %v", projectConfigSection, lang, key, err)
+			}
+		}
+		out.Languages[lang] = &lc
+	}
+
+	if err := out.Validate(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// commitMessagePolicy replaces cfg.CommitMessage with a copy that
+// may be modified, and returns it.
+func commitMessagePolicy(cfg *linter.Config) *linter.CommitMessagePolicy {
+	var cm linter.CommitMessagePolicy
+	if cfg.CommitMessage != nil {
+		cm = *cfg.CommitMessage
+	}
+	cfg.CommitMessage = &cm
+	return &cm
+}
+
+// identityPolicy replaces cfg.Identity with a copy that may be
+// modified, and returns it.
+func identityPolicy(cfg *linter.Config) *linter.IdentityPolicy {
+	var ip linter.IdentityPolicy
+	if cfg.Identity != nil {
+		ip = *cfg.Identity
+	}
+	cfg.Identity = &ip
+	return &ip
+}
+
+// appendMissing appends the elements of add that are not in list.
+func appendMissing(list, add []string) []string {
+	out := append([]string(nil), list...)
+	for _, a := range add {
+		found := false
+		for _, l := range out {
+			if l == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// parseBool parses a git-config boolean.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
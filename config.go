@@ -0,0 +1,308 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFileName is the name of the configuration file that
+// repositories can have at their root.
+const ConfigFileName = ".gerrit-linter.yaml"
+
+// Config holds the settings that may differ between repositories.
+type Config struct {
+	// Identity is the policy for author and committer identities.
+	Identity *IdentityPolicy `json:"identity,omitempty" yaml:"identity,omitempty"`
+
+	// DisableSkipFooter ignores SkipFooter in commit messages.
+	DisableSkipFooter bool `json:"disable_skip_footer,omitempty" yaml:"disable_skip_footer,omitempty"`
+
+	// DisableMarkers ignores the gerrit-linter:off and
+	// gerrit-linter:on markers in files.
+	DisableMarkers bool `json:"disable_markers,omitempty" yaml:"disable_markers,omitempty"`
+
+	// FileMode is the policy for file modes.
+	FileMode *FileModePolicy `json:"file_mode,omitempty" yaml:"file_mode,omitempty"`
+
+	// CommitMessage is the policy for commit messages.
+	CommitMessage *CommitMessagePolicy `json:"commit_message,omitempty" yaml:"commit_message,omitempty"`
+
+	// Whitespace configures the whitespace checker.
+	Whitespace *WhitespaceConfig `json:"whitespace,omitempty" yaml:"whitespace,omitempty"`
+
+	// Buildifier configures the lint warnings of the bzl checker.
+	Buildifier *BuildifierConfig `json:"buildifier,omitempty" yaml:"buildifier,omitempty"`
+
+	// ClangFormat configures the languages formatted by
+	// clang-format.
+	ClangFormat *ClangFormatConfig `json:"clang_format,omitempty" yaml:"clang_format,omitempty"`
+
+	// Python configures the python checker.
+	Python *PythonConfig `json:"python,omitempty" yaml:"python,omitempty"`
+
+	// Shellcheck configures the shellcheck stage of the shell
+	// checker.
+	Shellcheck *ShellcheckConfig `json:"shellcheck,omitempty" yaml:"shellcheck,omitempty"`
+
+	// Proto configures the proto-lint stage of the proto checker.
+	Proto *ProtoConfig `json:"proto,omitempty" yaml:"proto,omitempty"`
+
+	// Languages holds settings by language.
+	Languages map[string]*LanguageConfig `json:"languages,omitempty" yaml:"languages,omitempty"`
+}
+
+// LanguageConfig holds the settings for a single language.
+type LanguageConfig struct {
+	// Include, if set, selects the files to check by path globs
+	// (see MatchGlob), instead of the default for the language.
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+
+	// DetectContent also selects files whose shebang line,
+	// modeline or content shows that they are in the language (see
+	// DetectLanguage), eg. shell scripts without an extension.
+	DetectContent bool `json:"detect_content,omitempty" yaml:"detect_content,omitempty"`
+
+	// Exclude lists path globs of files that are not checked.
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+
+	// CheckGenerated checks generated files, which are skipped by
+	// default. Files are generated if they have a "Code generated
+	// ... DO NOT EDIT." line, or the linguist-generated or -diff
+	// attribute in .gitattributes.
+	CheckGenerated bool `json:"check_generated,omitempty" yaml:"check_generated,omitempty"`
+
+	// Args are options passed to the formatting tool, eg.
+	// ["--aosp"] for google-java-format. They must start with
+	// "-".
+	Args []string `json:"args,omitempty" yaml:"args,omitempty"`
+
+	// SkipStages lists pipeline stages that are not run, eg.
+	// ["goimports"].
+	SkipStages []string `json:"skip_stages,omitempty" yaml:"skip_stages,omitempty"`
+
+	// MaxLineLength is the limit of the line-length stage. If 0,
+	// line length is not checked.
+	MaxLineLength int `json:"max_line_length,omitempty" yaml:"max_line_length,omitempty"`
+
+	// Severity is SeverityError (the default), SeverityWarning
+	// for problems that are reported without failing the check,
+	// or SeverityOff to disable the check.
+	Severity string `json:"severity,omitempty" yaml:"severity,omitempty"`
+
+	// FailOn is the lowest severity of findings that fails the
+	// check: SeverityError (the default), SeverityWarning or
+	// SeverityInfo. Less severe findings are reported as warnings.
+	FailOn string `json:"fail_on,omitempty" yaml:"fail_on,omitempty"`
+
+	// BaseAware only reports problems on lines that the change
+	// edits, for files that were not clean before the change.
+	BaseAware bool `json:"base_aware,omitempty" yaml:"base_aware,omitempty"`
+
+	// ChangedLines restricts formatting to the lines that the
+	// change touches, for formatters that support it.
+	ChangedLines bool `json:"changed_lines,omitempty" yaml:"changed_lines,omitempty"`
+}
+
+// The values of LanguageConfig.Severity.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityOff     = "off"
+
+	// SeverityInfo is only used for findings and
+	// LanguageConfig.FailOn.
+	SeverityInfo = "info"
+)
+
+// CommitMessagePolicy configures the commitmsg checker.
+type CommitMessagePolicy struct {
+	// MaxSubjectLength is the maximum length of the subject line.
+	// If 0, it is 70.
+	MaxSubjectLength int `json:"max_subject_length,omitempty" yaml:"max_subject_length,omitempty"`
+
+	// RequiredFooters lists footers that must be present, eg.
+	// "Bug".
+	RequiredFooters []string `json:"required_footers,omitempty" yaml:"required_footers,omitempty"`
+}
+
+// ParseConfig parses a ConfigFileName file, and checks that it is
+// valid.
+func ParseConfig(content []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(content, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the settings, and sets up all configured
+// formatters to catch their errors.
+func (c *Config) Validate() error {
+	for lang, lc := range c.Languages {
+		if _, ok := GetFormatter(lang); !ok && !optionalLanguages[lang] {
+			return fmt.Errorf("languages: unknown language %q", lang)
+		}
+		if lc == nil {
+			continue
+		}
+		switch lc.Severity {
+		case "", SeverityError, SeverityWarning, SeverityOff:
+		default:
+			return fmt.Errorf("languages: %s: severity must be %q, %q or %q, got %q",
+				lang, SeverityError, SeverityWarning, SeverityOff, lc.Severity)
+		}
+		switch lc.FailOn {
+		case "", SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return fmt.Errorf("languages: %s: fail_on must be %q, %q or %q, got %q",
+				lang, SeverityError, SeverityWarning, SeverityInfo, lc.FailOn)
+		}
+		for _, a := range lc.Args {
+			if !strings.HasPrefix(a, "-") {
+				return fmt.Errorf("languages: %s: argument %q must start with '-'", lang, a)
+			}
+		}
+	}
+	if c.CommitMessage != nil && c.CommitMessage.MaxSubjectLength < 0 {
+		return fmt.Errorf("commit_message: max_subject_length must be positive")
+	}
+
+	for _, lang := range SupportedLanguages() {
+		entry, _ := GetFormatter(lang)
+		if _, err := entry.configure(c, lang); err != nil {
+			return fmt.Errorf("%s: %v", lang, err)
+		}
+	}
+	return nil
+}
+
+// Merge returns the configuration c, with the settings of o applied
+// on top. Settings that disable suppressions cannot be reverted by
+// o.
+func (c *Config) Merge(o *Config) *Config {
+	if c == nil {
+		c = &Config{}
+	}
+	if o == nil {
+		return c
+	}
+	out := *c
+	if o.Identity != nil {
+		out.Identity = o.Identity
+	}
+	out.DisableSkipFooter = c.DisableSkipFooter || o.DisableSkipFooter
+	out.DisableMarkers = c.DisableMarkers || o.DisableMarkers
+	if o.FileMode != nil {
+		out.FileMode = o.FileMode
+	}
+	if o.CommitMessage != nil {
+		out.CommitMessage = o.CommitMessage
+	}
+	if o.Whitespace != nil {
+		out.Whitespace = o.Whitespace
+	}
+	if o.Buildifier != nil {
+		out.Buildifier = o.Buildifier
+	}
+	if o.ClangFormat != nil {
+		out.ClangFormat = o.ClangFormat
+	}
+	if o.Python != nil {
+		out.Python = o.Python
+	}
+	if o.Shellcheck != nil {
+		out.Shellcheck = o.Shellcheck
+	}
+	if o.Proto != nil {
+		out.Proto = o.Proto
+	}
+
+	out.Languages = map[string]*LanguageConfig{}
+	for lang, lc := range c.Languages {
+		out.Languages[lang] = lc
+	}
+	for lang, lc := range o.Languages {
+		out.Languages[lang] = out.Language(lang).merge(lc)
+	}
+	return &out
+}
+
+// merge returns lc with the non-empty settings of o applied on top.
+func (lc *LanguageConfig) merge(o *LanguageConfig) *LanguageConfig {
+	out := *lc
+	if o == nil {
+		return &out
+	}
+	if o.Include != nil {
+		out.Include = o.Include
+	}
+	if o.Exclude != nil {
+		out.Exclude = o.Exclude
+	}
+	if o.Args != nil {
+		out.Args = o.Args
+	}
+	if o.SkipStages != nil {
+		out.SkipStages = o.SkipStages
+	}
+	if o.MaxLineLength != 0 {
+		out.MaxLineLength = o.MaxLineLength
+	}
+	if o.Severity != "" {
+		out.Severity = o.Severity
+	}
+	if o.FailOn != "" {
+		out.FailOn = o.FailOn
+	}
+	out.BaseAware = lc.BaseAware || o.BaseAware
+	out.ChangedLines = lc.ChangedLines || o.ChangedLines
+	out.CheckGenerated = lc.CheckGenerated || o.CheckGenerated
+	out.DetectContent = lc.DetectContent || o.DetectContent
+	return &out
+}
+
+// Language returns the settings for the given language. It never
+// returns nil.
+func (c *Config) Language(lang string) *LanguageConfig {
+	if c != nil {
+		if lc, ok := c.Languages[lang]; ok && lc != nil {
+			return lc
+		}
+	}
+	return &LanguageConfig{}
+}
+
+// configurable is implemented by formatters whose behavior depends
+// on the repository configuration.
+type configurable interface {
+	// configure returns a formatter set up for the given
+	// configuration, which may be nil, and language.
+	configure(cfg *Config, lang string) (Formatter, error)
+}
+
+// configure returns the formatter of the entry, set up for cfg.
+func (fc *FormatterConfig) configure(cfg *Config, lang string) (Formatter, error) {
+	if c, ok := fc.Formatter.(configurable); ok {
+		return c.configure(cfg, lang)
+	}
+	return fc.Formatter, nil
+}
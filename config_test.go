@@ -0,0 +1,118 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+commit_message:
+  max_subject_length: 60
+  required_footers: [Bug]
+languages:
+  commitmsg:
+    severity: warning
+  whitespace:
+    include: ["docs/**/*.md"]
+    exclude: [third_party/]
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.CommitMessage.MaxSubjectLength != 60 {
+		t.Errorf("got %+v", cfg.CommitMessage)
+	}
+	if got := cfg.Language("commitmsg").Severity; got != SeverityWarning {
+		t.Errorf("got severity %q", got)
+	}
+
+	for in, want := range map[string]string{
+		"languages:\n  klingon: {}\n":                  "unknown language",
+		"languages:\n  commitmsg: {severity: fatal}\n": "severity must be",
+		"languages:\n  whitespace: {args: [rm]}\n":     "must start with '-'",
+		"languages:\n  whitespace: {fail_on: x}\n":     "fail_on must be",
+		"identity:\n  name_pattern: '('\n":             "name_pattern",
+		"whitespace:\n  indent: [{glob: a, style: x}]": "indent style",
+		"unknown_setting: true\n":                      "unknown_setting",
+		"commit_message:\n  max_subject_length: -1\n":  "positive",
+	} {
+		_, err := ParseConfig([]byte(in))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseConfig(%q): got %v, want error containing %q", in, err, want)
+		}
+	}
+	// Languages whose tools may be missing on this host are known.
+	for _, lang := range []string{"java", "python", "shell", "prettier", "c", "cpp", "objc", "javascript"} {
+		if _, err := ParseConfig([]byte("languages:\n  " + lang + ": {args: [--x]}\n")); err != nil {
+			t.Errorf("ParseConfig(%s): %v", lang, err)
+		}
+	}
+}
+
+func TestConfigMerge(t *testing.T) {
+	host := &Config{
+		DisableSkipFooter: true,
+		Languages: map[string]*LanguageConfig{
+			"java": {Args: []string{"--aosp"}, BaseAware: true},
+		},
+	}
+	repo := &Config{
+		CommitMessage: &CommitMessagePolicy{MaxSubjectLength: 50},
+		Languages: map[string]*LanguageConfig{
+			"java": {Exclude: []string{"gen/"}},
+		},
+	}
+
+	got := host.Merge(repo)
+	want := &Config{
+		DisableSkipFooter: true,
+		CommitMessage:     &CommitMessagePolicy{MaxSubjectLength: 50},
+		Languages: map[string]*LanguageConfig{
+			"java": {Args: []string{"--aosp"}, Exclude: []string{"gen/"}, BaseAware: true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if host.Languages["java"].Exclude != nil {
+		t.Errorf("Merge modified its receiver")
+	}
+}
+
+func TestCommitMessagePolicy(t *testing.T) {
+	fmtr, err := (&commitMsgFormatter{}).configure(&Config{
+		CommitMessage: &CommitMessagePolicy{MaxSubjectLength: 10, RequiredFooters: []string{"Bug"}},
+	}, "commitmsg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"a long subject\n\nbody\n\nBug: 1\n": "less than 10",
+		"short\n\nbody\n":                    `footer "Bug" not found`,
+		"short\n\nbody\n\nBug: 1\n":          "",
+	} {
+		out, err := fmtr.Format([]File{{Name: "/COMMIT_MSG", Content: []byte(in)}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out[0].Message; !strings.Contains(got, want) || want == "" && got != "" {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// The languages that DetectLanguage returns.
+const (
+	DetectedShell      = "sh"
+	DetectedPython     = "python"
+	DetectedJavaScript = "javascript"
+	DetectedRuby       = "ruby"
+	DetectedPerl       = "perl"
+	DetectedGo         = "go"
+	DetectedJava       = "java"
+	DetectedStarlark   = "bzl"
+	DetectedMake       = "make"
+	DetectedDockerfile = "dockerfile"
+)
+
+// wellKnownNames maps base names of files without a telling
+// extension to their language.
+var wellKnownNames = map[string]string{
+	"configure":       DetectedShell,
+	"PKGBUILD":        DetectedShell,
+	".bashrc":         DetectedShell,
+	".bash_profile":   DetectedShell,
+	".profile":        DetectedShell,
+	"BUILD":           DetectedStarlark,
+	"BUILD.bazel":     DetectedStarlark,
+	"WORKSPACE":       DetectedStarlark,
+	"WORKSPACE.bazel": DetectedStarlark,
+	"SConstruct":      DetectedPython,
+	"SConscript":      DetectedPython,
+	"Rakefile":        DetectedRuby,
+	"Gemfile":         DetectedRuby,
+	"Makefile":        DetectedMake,
+	"GNUmakefile":     DetectedMake,
+	"Dockerfile":      DetectedDockerfile,
+}
+
+// languageAliases maps interpreter names in shebang lines, and
+// file types in modelines, to languages.
+var languageAliases = map[string]string{
+	"sh":           DetectedShell,
+	"bash":         DetectedShell,
+	"dash":         DetectedShell,
+	"ksh":          DetectedShell,
+	"shell-script": DetectedShell,
+	"python":       DetectedPython,
+	"node":         DetectedJavaScript,
+	"nodejs":       DetectedJavaScript,
+	"js":           DetectedJavaScript,
+	"javascript":   DetectedJavaScript,
+	"ruby":         DetectedRuby,
+	"perl":         DetectedPerl,
+	"go":           DetectedGo,
+	"java":         DetectedJava,
+	"bzl":          DetectedStarlark,
+	"starlark":     DetectedStarlark,
+	"make":         DetectedMake,
+	"makefile":     DetectedMake,
+	"dockerfile":   DetectedDockerfile,
+}
+
+// modelineLines is the number of lines at the start and end of a
+// file that are searched for modelines, as in vim.
+const modelineLines = 5
+
+var (
+	vimModelineRegex   = regexp.MustCompile(`\b(?:vim?|ex):.*\b(?:ft|filetype|syntax)=([\w-]+)`)
+	emacsModelineRegex = regexp.MustCompile(`-\*-\s*(?:.*\bmode:\s*([\w-]+)|([\w-]+))\s*(?:;.*)?-\*-`)
+	versionSuffixRegex = regexp.MustCompile(`[0-9.]+$`)
+)
+
+// sniffRules recognize files by content, when nothing else tells
+// their language.
+var sniffRules = []struct {
+	regex *regexp.Regexp
+	lang  string
+}{
+	{regexp.MustCompile(`(?m)^package \w+\s*$[\s\S]*^func `), DetectedGo},
+	{regexp.MustCompile(`(?m)^package [\w.]+;\s*$[\s\S]*\b(class|interface|enum)\b`), DetectedJava},
+	{regexp.MustCompile(`(?m)\A(#[^\n]*\n|\s*\n)*set -[euxo]`), DetectedShell},
+	{regexp.MustCompile(`(?m)^(from [\w.]+ )?import [\w.]+[\s\S]*^def \w+\(.*\):`), DetectedPython},
+}
+
+// DetectByName returns the language of files with a well-known
+// name, such as "configure" or "BUILD", or "" if the name is not
+// known.
+func DetectByName(name string) string {
+	return wellKnownNames[path.Base(name)]
+}
+
+// DetectLanguage returns the language of a file from its shebang
+// line, modelines, well-known name or content, in that order of
+// precedence. It returns "" if the language is not recognized.
+func DetectLanguage(name string, content []byte) string {
+	if lang := detectShebang(content); lang != "" {
+		return lang
+	}
+	if lang := detectModeline(content); lang != "" {
+		return lang
+	}
+	if lang := DetectByName(name); lang != "" {
+		return lang
+	}
+	for _, r := range sniffRules {
+		if r.regex.Match(content) {
+			return r.lang
+		}
+	}
+	return ""
+}
+
+// detectShebang returns the language of the interpreter named by a
+// "#!" line, eg. "#!/usr/bin/env python3".
+func detectShebang(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	first := string(content[2:])
+	if i := strings.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	fields := strings.Fields(first)
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := path.Base(fields[0])
+	if interp == "env" {
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = f
+				break
+			}
+		}
+	}
+	return languageAliases[versionSuffixRegex.ReplaceAllString(interp, "")]
+}
+
+// detectModeline returns the language set by a vim or emacs
+// modeline near the start or end of the file.
+func detectModeline(content []byte) string {
+	lines := strings.Split(string(content), "\n")
+	candidates := lines
+	if len(lines) > 2*modelineLines {
+		candidates = append(append([]string{}, lines[:modelineLines]...), lines[len(lines)-modelineLines:]...)
+	}
+	for _, l := range candidates {
+		if m := vimModelineRegex.FindStringSubmatch(l); m != nil {
+			if lang := languageAliases[strings.ToLower(m[1])]; lang != "" {
+				return lang
+			}
+		}
+		if m := emacsModelineRegex.FindStringSubmatch(l); m != nil {
+			mode := m[1]
+			if mode == "" {
+				mode = m[2]
+			}
+			if lang := languageAliases[strings.ToLower(mode)]; lang != "" {
+				return lang
+			}
+		}
+	}
+	return ""
+}
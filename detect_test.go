@@ -0,0 +1,69 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	for _, tc := range []struct {
+		name, content, want string
+	}{
+		{"tools/run", "#!/bin/sh\necho hi\n", DetectedShell},
+		{"tools/run", "#!/bin/bash -e\necho hi\n", DetectedShell},
+		{"tools/run", "#!/usr/bin/env python3\nprint(1)\n", DetectedPython},
+		{"tools/run", "#!/usr/bin/env -S python3.8 -u\nprint(1)\n", DetectedPython},
+		{"tools/run", "#!/usr/bin/env FOO=1 node\n", DetectedJavaScript},
+		{"tools/run", "#!/usr/bin/awk -f\n", ""},
+		{"script", "# vim: set ft=sh:\necho hi\n", DetectedShell},
+		{"script", "# -*- mode: python; coding: utf-8 -*-\n", DetectedPython},
+		{"script", "# -*- ruby -*-\n", DetectedRuby},
+		{"script", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n# vim: ft=perl\n", DetectedPerl},
+		{"script", "1\n2\n3\n4\n5\n# vim: ft=perl\n7\n8\n9\n10\n11\n12\n", ""},
+		{"configure", "echo hi\n", DetectedShell},
+		{"a/BUILD.bazel", "", DetectedStarlark},
+		{"configure", "#!/usr/bin/perl\n", DetectedPerl},
+		{"script", "# comment\n\nset -e\necho hi\n", DetectedShell},
+		{"gen", "// comment\npackage main\n\nfunc main() {}\n", DetectedGo},
+		{"gen", "package a.b;\n\npublic class C {}\n", DetectedJava},
+		{"gen", "import os\n\ndef main():\n  pass\n", DetectedPython},
+		{"notes", "just some text\n", ""},
+	} {
+		if got := DetectLanguage(tc.name, []byte(tc.content)); got != tc.want {
+			t.Errorf("DetectLanguage(%q, %q) = %q, want %q", tc.name, tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestMatchesContent(t *testing.T) {
+	fc := &FormatterConfig{Globs: []string{"*.sh"}, Detect: []string{DetectedShell}}
+	script := []byte("#!/bin/sh\necho hi\n")
+	for _, tc := range []struct {
+		name string
+		lc   LanguageConfig
+		want bool
+	}{
+		{"a.sh", LanguageConfig{}, true},
+		{"configure", LanguageConfig{}, true},
+		{"tools/run", LanguageConfig{}, false},
+		{"tools/run", LanguageConfig{DetectContent: true}, true},
+		{"tools/run", LanguageConfig{DetectContent: true, Exclude: []string{"tools/"}}, false},
+		{"tools/run", LanguageConfig{DetectContent: true, Include: []string{"*.sh"}}, false},
+		{"/COMMIT_MSG", LanguageConfig{DetectContent: true}, false},
+	} {
+		if got := fc.MatchesContent(tc.name, script, &tc.lc); got != tc.want {
+			t.Errorf("MatchesContent(%q, %+v) = %v, want %v", tc.name, tc.lc, got, tc.want)
+		}
+	}
+}
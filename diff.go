@@ -0,0 +1,258 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+)
+
+// LineRange is a range of lines [Start, End). Lines are numbered
+// from 1. If Start == End, the range is empty and denotes the
+// position before line Start.
+type LineRange struct {
+	Start, End int
+}
+
+// Contains returns true if the line is in the range.
+func (r LineRange) Contains(line int) bool {
+	return r.Start <= line && line < r.End
+}
+
+// Overlaps returns true if the ranges share a line. An empty range
+// overlaps the ranges that contain the lines on both sides of it.
+func (r LineRange) Overlaps(o LineRange) bool {
+	if r.Start == r.End {
+		return o.Start < r.Start && r.Start < o.End
+	}
+	if o.Start == o.End {
+		return o.Overlaps(r)
+	}
+	return r.Start < o.End && o.Start < r.End
+}
+
+// Hunk is a difference between two texts.
+type Hunk struct {
+	// A is the range of replaced lines in the old text, and B the
+	// range of lines replacing it in the new text.
+	A, B LineRange
+}
+
+// splitLines splits content into lines, keeping the line endings.
+func splitLines(content []byte) [][]byte {
+	var out [][]byte
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			i = len(content) - 1
+		}
+		out = append(out, content[:i+1])
+		content = content[i+1:]
+	}
+	return out
+}
+
+// Diff returns the hunks that transform a into b, in increasing
+// order.
+func Diff(a, b []byte) []Hunk {
+	return diffLines(splitLines(a), splitLines(b))
+}
+
+// ApplyHunks returns a with the given hunks of Diff(a, b) applied.
+func ApplyHunks(a, b []byte, hunks []Hunk) []byte {
+	aLines, bLines := splitLines(a), splitLines(b)
+	var out []byte
+	next := 0
+	for _, h := range hunks {
+		for ; next < h.A.Start-1; next++ {
+			out = append(out, aLines[next]...)
+		}
+		for i := h.B.Start - 1; i < h.B.End-1; i++ {
+			out = append(out, bLines[i]...)
+		}
+		next = h.A.End - 1
+	}
+	for ; next < len(aLines); next++ {
+		out = append(out, aLines[next]...)
+	}
+	return out
+}
+
+// maxDiffEdits bounds the number of edits the Myers search looks
+// for. The trace kept for backtracking grows quadratically with
+// the number of edits, so texts that differ more than this, such
+// as a reindented file, are reported as a single hunk.
+const maxDiffEdits = 1000
+
+// diffLines computes a line diff. Common leading and trailing lines
+// are never part of a hunk; the lines between them are compared with
+// Myers' algorithm, which finds a minimal diff.
+func diffLines(a, b [][]byte) []Hunk {
+	pre := 0
+	for pre < len(a) && pre < len(b) && bytes.Equal(a[pre], b[pre]) {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && bytes.Equal(a[len(a)-1-suf], b[len(b)-1-suf]) {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	hunks, ok := myers(a, b)
+	if !ok {
+		hunks = []Hunk{{
+			A: LineRange{1, len(a) + 1},
+			B: LineRange{1, len(b) + 1},
+		}}
+	}
+	for i := range hunks {
+		h := &hunks[i]
+		h.A.Start += pre
+		h.A.End += pre
+		h.B.Start += pre
+		h.B.End += pre
+	}
+	return hunks
+}
+
+// myers computes a minimal line diff with Myers' algorithm. It
+// returns false if that takes more than maxDiffEdits edits.
+func myers(a, b [][]byte) ([]Hunk, bool) {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil, true
+	}
+	max := n + m
+	v := make([]int, 2*max+2)
+
+	// trace[d] holds v[k] for k in [-d, d] after d edits.
+	var trace [][]int
+	for d := 0; d <= max && d <= maxDiffEdits; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(a[x], b[y]) {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m, d), true
+			}
+		}
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+	}
+	return nil, false
+}
+
+// backtrack reconstructs the hunks from the trace of the Myers
+// search that reached (n, m) in d edits.
+func backtrack(trace [][]int, n, m, d int) []Hunk {
+	// matches holds the pairs of equal lines, in reverse order.
+	type match struct{ x, y int }
+	var matches []match
+
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d-1]
+		at := func(k int) int { return v[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, match{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		matches = append(matches, match{x, y})
+	}
+
+	var hunks []Hunk
+	ai, bi := 0, 0
+	for i := len(matches) - 1; i >= -1; i-- {
+		nx, ny := n, m
+		if i >= 0 {
+			nx, ny = matches[i].x, matches[i].y
+		}
+		if nx > ai || ny > bi {
+			hunks = append(hunks, Hunk{
+				A: LineRange{ai + 1, nx + 1},
+				B: LineRange{bi + 1, ny + 1},
+			})
+		}
+		ai, bi = nx+1, ny+1
+	}
+	return hunks
+}
+
+// MapLine maps a line of a to the corresponding line of b, given
+// the hunks of Diff(a, b). Lines replaced by a hunk map to the
+// start of its replacement.
+func MapLine(hunks []Hunk, line int) int {
+	shift := 0
+	for _, h := range hunks {
+		if line < h.A.Start {
+			break
+		}
+		if line < h.A.End {
+			return h.B.Start
+		}
+		shift = h.B.End - h.A.End
+	}
+	return line + shift
+}
+
+// MapRange maps a range of lines of a to the corresponding range
+// of b, given the hunks of Diff(a, b). The result covers the
+// replacements of the hunks that r overlaps.
+func MapRange(hunks []Hunk, r LineRange) LineRange {
+	start := MapLine(hunks, r.Start)
+	if r.Start == r.End {
+		return LineRange{Start: start, End: start}
+	}
+	end := MapLine(hunks, r.End-1) + 1
+	for _, h := range hunks {
+		if h.A.Contains(r.End - 1) {
+			end = h.B.End
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return LineRange{Start: start, End: end}
+}
+
+// invertHunks returns the hunks of Diff(b, a), given those of
+// Diff(a, b).
+func invertHunks(hunks []Hunk) []Hunk {
+	out := make([]Hunk, len(hunks))
+	for i, h := range hunks {
+		out[i] = Hunk{A: h.B, B: h.A}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want []Hunk
+	}{
+		{"a\nb\n", "a\nb\n", nil},
+		{"a\nb\nc\n", "a\nx\nc\n", []Hunk{{LineRange{2, 3}, LineRange{2, 3}}}},
+		{"a\nc\n", "a\nb\nc\n", []Hunk{{LineRange{2, 2}, LineRange{2, 3}}}},
+		{"a\nb\nc\n", "b\n", []Hunk{
+			{LineRange{1, 2}, LineRange{1, 1}},
+			{LineRange{3, 4}, LineRange{2, 2}},
+		}},
+		{"a", "a\n", []Hunk{{LineRange{1, 2}, LineRange{1, 2}}}},
+		{"", "a\n", []Hunk{{LineRange{1, 1}, LineRange{1, 2}}}},
+	} {
+		got := Diff([]byte(tc.a), []byte(tc.b))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Diff(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+// numberedLines returns n lines, with prefix before the line
+// number on the lines selected by sel.
+func numberedLines(n int, prefix string, sel func(int) bool) []byte {
+	var buf bytes.Buffer
+	for i := 1; i <= n; i++ {
+		if sel(i) {
+			buf.WriteString(prefix)
+		}
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+	return buf.Bytes()
+}
+
+func TestDiffLarge(t *testing.T) {
+	a := numberedLines(4000, "", func(int) bool { return false })
+
+	// A reindented file differs in more lines than the search
+	// looks at, and comes back as a single hunk.
+	b := numberedLines(4000, "\t", func(i int) bool { return i > 1 && i < 4000 })
+	want := []Hunk{{LineRange{2, 4000}, LineRange{2, 4000}}}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("reindented: got %v, want %v", got, want)
+	}
+
+	// Few scattered edits are still diffed line by line.
+	b = numberedLines(4000, "\t", func(i int) bool { return i == 100 || i == 3000 })
+	want = []Hunk{
+		{LineRange{100, 101}, LineRange{100, 101}},
+		{LineRange{3000, 3001}, LineRange{3000, 3001}},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("scattered: got %v, want %v", got, want)
+	}
+	if got := ApplyHunks(a, b, Diff(a, b)); !bytes.Equal(got, b) {
+		t.Errorf("scattered: applying all hunks does not give b")
+	}
+}
+
+func BenchmarkDiffReindented(b *testing.B) {
+	x := numberedLines(4000, "", func(int) bool { return false })
+	y := numberedLines(4000, "\t", func(i int) bool { return i%2 == 0 })
+	for i := 0; i < b.N; i++ {
+		Diff(x, y)
+	}
+}
+
+func TestApplyHunks(t *testing.T) {
+	a := []byte("a\nb\nc\nd\n")
+	b := []byte("A\nb\nc\nD\n")
+	hunks := Diff(a, b)
+	if len(hunks) != 2 {
+		t.Fatalf("got hunks %v", hunks)
+	}
+	if got := string(ApplyHunks(a, b, hunks)); got != string(b) {
+		t.Errorf("all hunks: got %q", got)
+	}
+	if got, want := string(ApplyHunks(a, b, hunks[1:])), "a\nb\nc\nD\n"; got != want {
+		t.Errorf("last hunk: got %q, want %q", got, want)
+	}
+	if got := string(ApplyHunks(a, b, nil)); got != string(a) {
+		t.Errorf("no hunks: got %q", got)
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	for _, tc := range []struct {
+		a, b LineRange
+		want bool
+	}{
+		{LineRange{1, 3}, LineRange{2, 4}, true},
+		{LineRange{1, 3}, LineRange{3, 4}, false},
+		{LineRange{3, 3}, LineRange{2, 4}, true},
+		{LineRange{2, 2}, LineRange{2, 4}, false},
+		{LineRange{2, 4}, LineRange{4, 4}, false},
+		{LineRange{2, 5}, LineRange{4, 4}, true},
+	} {
+		if got := tc.a.Overlaps(tc.b); got != tc.want {
+			t.Errorf("%v.Overlaps(%v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestMapLine(t *testing.T) {
+	// a, b, c, d, e -> a, X, Y, c, e
+	hunks := Diff([]byte("a\nb\nc\nd\ne\n"), []byte("a\nX\nY\nc\ne\n"))
+	for line, want := range map[int]int{1: 1, 2: 2, 3: 4, 4: 5, 5: 5} {
+		if got := MapLine(hunks, line); got != want {
+			t.Errorf("MapLine(%d) = %d, want %d", line, got, want)
+		}
+	}
+	for r, want := range map[LineRange]LineRange{
+		{Start: 1, End: 2}: {Start: 1, End: 2},
+		{Start: 2, End: 3}: {Start: 2, End: 4},
+		{Start: 3, End: 5}: {Start: 4, End: 5},
+		{Start: 4, End: 4}: {Start: 5, End: 5},
+	} {
+		if got := MapRange(hunks, r); got != want {
+			t.Errorf("MapRange(%v) = %v, want %v", r, got, want)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// editorConfigName is the name of EditorConfig files, see
+// https://editorconfig.org.
+const editorConfigName = ".editorconfig"
+
+// editorConfig is a parsed .editorconfig file.
+type editorConfig struct {
+	// dir is the directory holding the file, "" for the root.
+	dir      string
+	root     bool
+	sections []editorConfigSection
+}
+
+type editorConfigSection struct {
+	glob  string
+	re    *regexp.Regexp
+	props map[string]string
+}
+
+// parseEditorConfig parses the .editorconfig file at the given path.
+func parseEditorConfig(name string, content []byte) (*editorConfig, error) {
+	ec := &editorConfig{dir: path.Dir(name)}
+	if ec.dir == "." || ec.dir == "/" {
+		ec.dir = ""
+	}
+
+	var section *editorConfigSection
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+		if l[0] == '[' && l[len(l)-1] == ']' {
+			glob := l[1 : len(l)-1]
+			re, err := editorConfigRegexp(glob)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, lineNo, err)
+			}
+			ec.sections = append(ec.sections, editorConfigSection{
+				glob:  glob,
+				re:    re,
+				props: map[string]string{},
+			})
+			section = &ec.sections[len(ec.sections)-1]
+			continue
+		}
+
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s:%d: want 'key = value', got %q", name, lineNo, l)
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		val := strings.ToLower(strings.TrimSpace(kv[1]))
+		if section == nil {
+			if key == "root" {
+				ec.root = val == "true"
+			}
+			continue
+		}
+		section.props[key] = val
+	}
+	return ec, scanner.Err()
+}
+
+// editorConfigRegexp translates an EditorConfig section glob to a
+// regular expression for paths relative to the directory of the
+// .editorconfig file.
+func editorConfigRegexp(glob string) (*regexp.Regexp, error) {
+	prefix := "^"
+	if strings.HasPrefix(glob, "/") {
+		glob = glob[1:]
+	} else if !strings.Contains(glob, "/") {
+		prefix = "^(?:.*/)?"
+	}
+
+	expr, rest, err := translateGlob(glob, false)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("glob %q: unexpected %q", glob, rest)
+	}
+	return regexp.Compile(prefix + expr + "$")
+}
+
+var numRangeRegex = regexp.MustCompile(`^\{(-?[0-9]+)\.\.(-?[0-9]+)\}`)
+
+// translateGlob translates glob into a regular expression. Inside
+// braces, it stops at the first top-level ',' or '}', and returns
+// the remainder.
+func translateGlob(glob string, inBraces bool) (expr string, rest string, err error) {
+	var b strings.Builder
+	for len(glob) > 0 {
+		c := glob[0]
+		switch {
+		case inBraces && (c == ',' || c == '}'):
+			return b.String(), glob, nil
+		case strings.HasPrefix(glob, "**"):
+			b.WriteString(".*")
+			glob = glob[2:]
+			continue
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob, ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				break
+			}
+			class := glob[1:end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			glob = glob[end+1:]
+			continue
+		case c == '{':
+			// Numeric ranges match any integer; the bounds
+			// are not checked.
+			if m := numRangeRegex.FindStringSubmatch(glob); m != nil {
+				b.WriteString(`(?:-?[0-9]+)`)
+				glob = glob[len(m[0]):]
+				continue
+			}
+			var alts []string
+			rest := glob[1:]
+			for {
+				var alt string
+				alt, rest, err = translateGlob(rest, true)
+				if err != nil {
+					return "", "", err
+				}
+				alts = append(alts, alt)
+				if rest == "" {
+					return "", "", fmt.Errorf("unterminated '{' in glob")
+				}
+				sep := rest[0]
+				rest = rest[1:]
+				if sep == '}' {
+					break
+				}
+			}
+			b.WriteString("(?:" + strings.Join(alts, "|") + ")")
+			glob = rest
+			continue
+		case c == '\\' && len(glob) > 1:
+			b.WriteString(regexp.QuoteMeta(glob[1:2]))
+			glob = glob[2:]
+			continue
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[:1]))
+		}
+		glob = glob[1:]
+	}
+	return b.String(), "", nil
+}
+
+// resolveEditorConfig returns the properties that apply to the file
+// name. The configs must be ordered from the root down.
+func resolveEditorConfig(configs []*editorConfig, name string) map[string]string {
+	start := 0
+	for i, ec := range configs {
+		if ec.root && isUnder(name, ec.dir) {
+			start = i
+		}
+	}
+
+	props := map[string]string{}
+	for _, ec := range configs[start:] {
+		if !isUnder(name, ec.dir) {
+			continue
+		}
+		rel := name
+		if ec.dir != "" {
+			rel = name[len(ec.dir)+1:]
+		}
+		for _, s := range ec.sections {
+			if !s.re.MatchString(rel) {
+				continue
+			}
+			for k, v := range s.props {
+				if v == "unset" {
+					delete(props, k)
+				} else {
+					props[k] = v
+				}
+			}
+		}
+	}
+	return props
+}
+
+// isUnder returns true if name is in directory dir, or one of its
+// subdirectories.
+func isUnder(name, dir string) bool {
+	return dir == "" || strings.HasPrefix(name, dir+"/")
+}
+
+// editorConfigFormatter enforces the .editorconfig files passed as
+// support files on the other files.
+type editorConfigFormatter struct{}
+
+func (f *editorConfigFormatter) Format(in []File, outSink io.Writer) (out []FormattedFile, err error) {
+	var configs []*editorConfig
+	for _, file := range in {
+		if !file.Support {
+			continue
+		}
+		ec, err := parseEditorConfig(file.Name, file.Content)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, ec)
+	}
+	depth := func(dir string) int {
+		if dir == "" {
+			return 0
+		}
+		return strings.Count(dir, "/") + 1
+	}
+	sort.SliceStable(configs, func(i, j int) bool {
+		return depth(configs[i].dir) < depth(configs[j].dir)
+	})
+
+	for _, file := range in {
+		if file.Support {
+			continue
+		}
+		ff := FormattedFile{}
+		ff.Name = file.Name
+		ff.Content, ff.Findings = checkEditorConfig(resolveEditorConfig(configs, file.Name), file.Content)
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+// line is a line of text with its line ending, which is empty for
+// the last line of a file without final newline.
+type line struct {
+	text, eol []byte
+}
+
+// splitEOL splits content into lines, recognizing "\r\n", "\n" and
+// "\r" line endings.
+func splitEOL(content []byte) []line {
+	var out []line
+	for len(content) > 0 {
+		i := bytes.IndexAny(content, "\r\n")
+		if i < 0 {
+			out = append(out, line{text: content})
+			break
+		}
+		n := 1
+		if content[i] == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+			n = 2
+		}
+		out = append(out, line{text: content[:i], eol: content[i : i+n]})
+		content = content[i+n:]
+	}
+	return out
+}
+
+var eolNames = map[string]string{"lf": "\n", "crlf": "\r\n", "cr": "\r"}
+
+// checkEditorConfig checks content against the EditorConfig
+// properties, and returns the fixed content and the findings.
+// Indentation, line length and charset problems are not fixed.
+func checkEditorConfig(props map[string]string, content []byte) ([]byte, []Finding) {
+	var findings []Finding
+	if len(props) == 0 {
+		return content, nil
+	}
+
+	switch props["charset"] {
+	case "utf-8":
+		if bytes.HasPrefix(content, utf8BOM) {
+			findings = append(findings, Finding{Line: 1, Message: "charset utf-8: must not start with a byte order mark"})
+			content = content[len(utf8BOM):]
+		}
+	case "utf-8-bom":
+		if !bytes.HasPrefix(content, utf8BOM) {
+			findings = append(findings, Finding{Line: 1, Message: "charset utf-8-bom: must start with a byte order mark"})
+			content = append(append([]byte{}, utf8BOM...), content...)
+		}
+	}
+	checkUTF8 := props["charset"] == "utf-8" || props["charset"] == "utf-8-bom"
+
+	eol, fixEOL := eolNames[props["end_of_line"]]
+	indentSize, _ := strconv.Atoi(props["indent_size"])
+	if props["indent_size"] == "tab" || indentSize == 0 {
+		indentSize, _ = strconv.Atoi(props["tab_width"])
+	}
+	maxLen, _ := strconv.Atoi(props["max_line_length"])
+
+	var fixed []byte
+	wrongEOL, wrongEOLFinding := 0, 0
+	lines := splitEOL(content)
+	for i, l := range lines {
+		lineNo := i + 1
+		text := l.text
+		if checkUTF8 && !utf8.Valid(text) {
+			findings = append(findings, Finding{Line: lineNo, Message: "invalid UTF-8"})
+		}
+
+		if props["trim_trailing_whitespace"] == "true" {
+			trimmed := bytes.TrimRight(text, " \t")
+			if len(trimmed) < len(text) {
+				findings = append(findings, Finding{Line: lineNo, Message: "trailing whitespace"})
+				text = trimmed
+			}
+		}
+
+		indent := text[:len(text)-len(bytes.TrimLeft(text, " \t"))]
+		rest := bytes.TrimLeft(text, " \t")
+		switch props["indent_style"] {
+		case "tab":
+			spaces := len(indent) - len(bytes.TrimLeft(indent, " "))
+			if spaces > 0 && spaces >= indentSize && len(rest) > 0 {
+				findings = append(findings, Finding{Line: lineNo, Message: "indent_style tab: must indent with tabs"})
+			}
+		case "space":
+			if bytes.IndexByte(indent, '\t') >= 0 {
+				findings = append(findings, Finding{Line: lineNo, Message: "indent_style space: must indent with spaces"})
+			} else if indentSize > 0 && len(indent)%indentSize != 0 && len(rest) > 0 && rest[0] != '*' {
+				findings = append(findings, Finding{Line: lineNo,
+					Message: fmt.Sprintf("indent_size %d: indentation of %d spaces", indentSize, len(indent))})
+			}
+		}
+
+		if maxLen > 0 {
+			if n := utf8.RuneCount(text); n > maxLen {
+				findings = append(findings, Finding{Line: lineNo,
+					Message: fmt.Sprintf("max_line_length %d: line has %d characters", maxLen, n)})
+			}
+		}
+
+		lineEOL := l.eol
+		if len(lineEOL) > 0 && fixEOL && string(lineEOL) != eol {
+			if wrongEOL == 0 {
+				wrongEOLFinding = len(findings)
+				findings = append(findings, Finding{Line: lineNo,
+					Message: fmt.Sprintf("end_of_line %s: wrong line ending", props["end_of_line"])})
+			}
+			wrongEOL++
+			lineEOL = []byte(eol)
+		}
+
+		if i == len(lines)-1 {
+			switch props["insert_final_newline"] {
+			case "true":
+				if len(lineEOL) == 0 {
+					findings = append(findings, Finding{Line: lineNo, Message: "insert_final_newline: must end in a newline"})
+					lineEOL = []byte(eol)
+					if !fixEOL {
+						lineEOL = []byte("\n")
+					}
+				}
+			case "false":
+				if len(lineEOL) > 0 {
+					findings = append(findings, Finding{Line: lineNo, Message: "insert_final_newline false: must not end in a newline"})
+					lineEOL = nil
+				}
+			}
+		}
+
+		fixed = append(fixed, text...)
+		fixed = append(fixed, lineEOL...)
+	}
+	if wrongEOL > 1 {
+		findings[wrongEOLFinding].Message += fmt.Sprintf(" (%d lines)", wrongEOL)
+	}
+	return fixed, findings
+}
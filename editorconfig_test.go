@@ -0,0 +1,142 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditorConfigGlob(t *testing.T) {
+	for _, tc := range []struct {
+		glob, name string
+		want       bool
+	}{
+		{"*", "a.go", true},
+		{"*", "dir/a.go", true},
+		{"*.go", "dir/a.go", true},
+		{"*.{js,ts}", "src/a.ts", true},
+		{"*.{js,ts}", "src/a.go", false},
+		{"/Makefile", "Makefile", true},
+		{"/Makefile", "sub/Makefile", false},
+		{"lib/**.js", "lib/a/b.js", true},
+		{"lib/*.js", "lib/a/b.js", false},
+		{"[!a]bc", "xbc", true},
+		{"[!a]bc", "abc", false},
+		{"file{1..3}.txt", "file2.txt", true},
+	} {
+		re, err := editorConfigRegexp(tc.glob)
+		if err != nil {
+			t.Errorf("%q: %v", tc.glob, err)
+			continue
+		}
+		if got := re.MatchString(tc.name); got != tc.want {
+			t.Errorf("%q (%s) on %q: got %v, want %v", tc.glob, re, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEditorConfigResolve(t *testing.T) {
+	root, err := parseEditorConfig(".editorconfig", []byte(`root = true
+
+[*]
+indent_style = space
+indent_size = 4
+insert_final_newline = true
+
+[Makefile]
+indent_style = tab
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := parseEditorConfig("web/.editorconfig", []byte(`
+[*.js]
+indent_size = 2
+max_line_length = unset
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := []*editorConfig{root, sub}
+
+	got := resolveEditorConfig(configs, "web/app.js")
+	want := map[string]string{"indent_style": "space", "indent_size": "2", "insert_final_newline": "true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("web/app.js: got %v, want %v", got, want)
+	}
+	if got := resolveEditorConfig(configs, "tools/Makefile")["indent_style"]; got != "tab" {
+		t.Errorf("tools/Makefile: got indent_style %q", got)
+	}
+	if got := resolveEditorConfig(configs, "app.js")["indent_size"]; got != "4" {
+		t.Errorf("app.js: got indent_size %q", got)
+	}
+}
+
+func TestEditorConfigCheck(t *testing.T) {
+	for _, tc := range []struct {
+		props    map[string]string
+		in, want string
+		findings string
+	}{
+		{map[string]string{"trim_trailing_whitespace": "true", "insert_final_newline": "true"},
+			"a \nb", "a\nb\n", "trailing whitespace; insert_final_newline"},
+		{map[string]string{"insert_final_newline": "false"}, "a\n", "a", "must not end"},
+		{map[string]string{"end_of_line": "lf"}, "a\r\nb\r\n", "a\nb\n", "wrong line ending (2 lines)"},
+		{map[string]string{"end_of_line": "crlf"}, "a\nb\r\n", "a\r\nb\r\n", "wrong line ending"},
+		{map[string]string{"indent_style": "space", "indent_size": "4"}, "a\n\tb\n   c\n    d\n", "a\n\tb\n   c\n    d\n",
+			"must indent with spaces; indent_size 4"},
+		{map[string]string{"indent_style": "tab", "indent_size": "4"}, "\ta\n    b\n */\n", "\ta\n    b\n */\n", "must indent with tabs"},
+		{map[string]string{"max_line_length": "3"}, "abc\nabcd\n", "abc\nabcd\n", "line has 4 characters"},
+		{map[string]string{"charset": "utf-8"}, "\xef\xbb\xbfa\n", "a\n", "byte order mark"},
+		{map[string]string{"charset": "utf-8-bom"}, "a\n", "\xef\xbb\xbfa\n", "byte order mark"},
+		{nil, "a \r\n", "a \r\n", ""},
+	} {
+		got, findings := checkEditorConfig(tc.props, []byte(tc.in))
+		if string(got) != tc.want {
+			t.Errorf("%v %q: got %q, want %q", tc.props, tc.in, got, tc.want)
+		}
+		var msgs []string
+		for _, f := range findings {
+			msgs = append(msgs, f.Message)
+		}
+		gotFindings := strings.Join(msgs, "; ")
+		if tc.findings == "" && gotFindings != "" || !strings.Contains(gotFindings, tc.findings) {
+			t.Errorf("%v %q: got findings %q, want %q", tc.props, tc.in, gotFindings, tc.findings)
+		}
+	}
+}
+
+func TestEditorConfigFormat(t *testing.T) {
+	out, err := (&editorConfigFormatter{}).Format([]File{
+		{Name: "a.txt", Content: []byte("x \n")},
+		{Name: ".editorconfig", Content: []byte("[*]\ntrim_trailing_whitespace = true\n"), Support: true},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Name != "a.txt" || string(out[0].Content) != "x\n" || len(out[0].Findings) != 1 {
+		t.Errorf("got %+v", out)
+	}
+}
+
+func TestSupportPaths(t *testing.T) {
+	got := SupportPaths([]string{".editorconfig"}, []string{"a/b/c.go", "a/d.go", "e.go"})
+	want := []string{".editorconfig", "a/.editorconfig", "a/b/.editorconfig"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/gerrit-linter/gerrit"
+)
+
+// FileModePolicy describes which files may be executable.
+type FileModePolicy struct {
+	// NonExecutable lists the file extensions, eg. ".go", of
+	// files that must not be executable. If empty,
+	// defaultNonExecutable is used.
+	NonExecutable []string `json:"non_executable,omitempty" yaml:"non_executable,omitempty"`
+}
+
+// defaultNonExecutable are extensions of source files that are never
+// run directly.
+var defaultNonExecutable = []string{
+	".bzl", ".c", ".cc", ".cpp", ".css", ".go", ".h", ".hpp", ".html",
+	".java", ".js", ".json", ".kt", ".md", ".proto", ".ts", ".txt",
+	".xml", ".yaml", ".yml",
+}
+
+// fileModeFormatter checks executable bits and symlink targets.
+type fileModeFormatter struct {
+	nonExecutable []string
+}
+
+func (f *fileModeFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	out := &fileModeFormatter{nonExecutable: defaultNonExecutable}
+	if cfg != nil && cfg.FileMode != nil && len(cfg.FileMode.NonExecutable) > 0 {
+		out.nonExecutable = cfg.FileMode.NonExecutable
+	}
+	return out, nil
+}
+
+func (f *fileModeFormatter) Format(in []File, outSink io.Writer) (out []FormattedFile, err error) {
+	for _, file := range in {
+		ff := FormattedFile{File: file}
+		ff.Findings = f.check(&file)
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+func (f *fileModeFormatter) check(file *File) []Finding {
+	switch file.Mode {
+	case gerrit.ModeSymlink:
+		target := string(file.Content)
+		if path.IsAbs(target) {
+			return []Finding{{Message: fmt.Sprintf("symlink target %q must be relative", target)}}
+		}
+		dest := path.Clean(path.Join(path.Dir(file.Name), target))
+		if dest == ".." || strings.HasPrefix(dest, "../") {
+			return []Finding{{Message: fmt.Sprintf("symlink target %q points outside the repository", target)}}
+		}
+	case gerrit.ModeRegular:
+		if bytes.HasPrefix(file.Content, []byte("#!")) {
+			return []Finding{{Line: 1, Message: "file with a shebang must be executable"}}
+		}
+	case gerrit.ModeExecutable:
+		ext := path.Ext(file.Name)
+		for _, e := range f.nonExecutable {
+			if ext == e {
+				return []Finding{{Message: fmt.Sprintf("%s files must not be executable", ext)}}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/gerrit-linter/gerrit"
+)
+
+func TestFileMode(t *testing.T) {
+	fmtr, err := (&fileModeFormatter{}).configure(nil, "filemode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fmtr.(*fileModeFormatter)
+
+	for _, tc := range []struct {
+		file File
+		want string
+	}{
+		{File{Name: "run.sh", Mode: gerrit.ModeExecutable, Content: []byte("#!/bin/sh\n")}, ""},
+		{File{Name: "run.sh", Mode: gerrit.ModeRegular, Content: []byte("#!/bin/sh\n")}, "must be executable"},
+		{File{Name: "run.sh", Content: []byte("#!/bin/sh\n")}, ""},
+		{File{Name: "main.go", Mode: gerrit.ModeRegular, Content: []byte("package main\n")}, ""},
+		{File{Name: "main.go", Mode: gerrit.ModeExecutable, Content: []byte("package main\n")}, "must not be executable"},
+		{File{Name: "lib/current", Mode: gerrit.ModeSymlink, Content: []byte("v1/lib")}, ""},
+		{File{Name: "lib/current", Mode: gerrit.ModeSymlink, Content: []byte("../docs")}, ""},
+		{File{Name: "lib/current", Mode: gerrit.ModeSymlink, Content: []byte("/usr/lib")}, "must be relative"},
+		{File{Name: "lib/current", Mode: gerrit.ModeSymlink, Content: []byte("../../etc")}, "outside the repository"},
+	} {
+		var got []string
+		for _, finding := range f.check(&tc.file) {
+			got = append(got, finding.Message)
+		}
+		gotStr := strings.Join(got, "; ")
+		if tc.want == "" && gotStr != "" {
+			t.Errorf("%s: want empty, got %s", tc.file.Name, gotStr)
+		} else if !strings.Contains(gotStr, tc.want) {
+			t.Errorf("%s: got %s, want substring %s", tc.file.Name, gotStr, tc.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// GitAttributesName is the name of the files holding git attributes.
+const GitAttributesName = ".gitattributes"
+
+// generatedRegex matches the marker of generated files
+// (https://golang.org/s/generatedcode), after any comment leader.
+var generatedRegex = regexp.MustCompile(`(?m)^\s*(//|#|--|;|/?\*)\s*Code generated .* DO NOT EDIT\.\s*(\*/)?\s*$`)
+
+// IsGeneratedContent returns true if the content has the "Code
+// generated ... DO NOT EDIT." marker.
+func IsGeneratedContent(content []byte) bool {
+	return generatedRegex.Match(content)
+}
+
+// attributeRule is a line of a .gitattributes file.
+type attributeRule struct {
+	// pattern is relative to the directory of the file. A leading
+	// "/" anchors it there.
+	pattern string
+
+	// attrs maps attribute names to "true" if set, "false" if
+	// unset, or their value.
+	attrs map[string]string
+}
+
+// parseGitAttributes parses a .gitattributes file. Macro
+// definitions are ignored, except for the built-in "binary".
+func parseGitAttributes(content []byte) []attributeRule {
+	var out []attributeRule
+	for _, l := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		rule := attributeRule{
+			pattern: fields[0],
+			attrs:   map[string]string{},
+		}
+		for _, a := range fields[1:] {
+			switch {
+			case a == "binary":
+				rule.attrs["diff"] = "false"
+				rule.attrs["merge"] = "false"
+				rule.attrs["text"] = "false"
+			case strings.HasPrefix(a, "-"):
+				rule.attrs[a[1:]] = "false"
+			case strings.HasPrefix(a, "!"):
+				rule.attrs[a[1:]] = ""
+			case strings.Contains(a, "="):
+				i := strings.Index(a, "=")
+				rule.attrs[a[:i]] = a[i+1:]
+			default:
+				rule.attrs[a] = "true"
+			}
+		}
+		out = append(out, rule)
+	}
+	return out
+}
+
+// matches returns true if the rule applies to the file with the
+// given name, relative to the directory of the rule. As in git, a
+// pattern ending in "/" matches directories only, and so no files.
+func (r attributeRule) matches(name string) bool {
+	if strings.HasSuffix(r.pattern, "/") {
+		return false
+	}
+	if strings.HasPrefix(r.pattern, "/") {
+		return matchComponents(strings.Split(r.pattern[1:], "/"), strings.Split(name, "/"))
+	}
+	return MatchGlob(r.pattern, name)
+}
+
+// GitAttributes returns the attributes of a file, given the
+// .gitattributes files of the repository by path. As in git, files
+// deeper in the tree and later lines take precedence.
+func GitAttributes(attrFiles map[string][]byte, name string) map[string]string {
+	out := map[string]string{}
+	for _, p := range SupportPaths([]string{GitAttributesName}, []string{name}) {
+		content, ok := attrFiles[p]
+		if !ok {
+			continue
+		}
+		rel := name
+		if dir := path.Dir(p); dir != "." {
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		for _, r := range parseGitAttributes(content) {
+			if !r.matches(rel) {
+				continue
+			}
+			for k, v := range r.attrs {
+				if v == "" {
+					delete(out, k)
+				} else {
+					out[k] = v
+				}
+			}
+		}
+	}
+	return out
+}
+
+// IsGeneratedByAttributes returns true if the .gitattributes files
+// mark the file as generated, with linguist-generated or -diff.
+func IsGeneratedByAttributes(attrFiles map[string][]byte, name string) bool {
+	attrs := GitAttributes(attrFiles, name)
+	return attrs["linguist-generated"] == "true" || attrs["diff"] == "false"
+}
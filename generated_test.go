@@ -0,0 +1,76 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import "testing"
+
+func TestIsGeneratedContent(t *testing.T) {
+	for in, want := range map[string]bool{
+		"// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage x\n": true,
+		"package x\n// Code generated by hand. DO NOT EDIT.\n":            true,
+		"# Code generated by gen.sh. DO NOT EDIT.\nset -e\n":              true,
+		"/* Code generated by yacc. DO NOT EDIT. */\n":                    true,
+		"// Code generated by protoc-gen-go. Please edit.\n":              false,
+		"x := \"// Code generated by a. DO NOT EDIT.\"\n":                 false,
+		"package x\n": false,
+	} {
+		if got := IsGeneratedContent([]byte(in)); got != want {
+			t.Errorf("IsGeneratedContent(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestIsGeneratedByAttributes(t *testing.T) {
+	attrFiles := map[string][]byte{
+		".gitattributes": []byte(`# comment
+*.pb.go linguist-generated
+/vendor/** -diff
+*.png binary
+docs/*.md linguist-generated=true
+/top.go linguist-generated
+gen/ linguist-generated
+`),
+		"api/.gitattributes": []byte(`
+*.pb.go -linguist-generated
+schema.json linguist-generated
+/local.json linguist-generated
+`),
+	}
+	for name, want := range map[string]bool{
+		"foo.pb.go":              true,
+		"sub/foo.pb.go":          true,
+		"api/foo.pb.go":          false,
+		"api/schema.json":        true,
+		"schema.json":            false,
+		"vendor/a/b.go":          true,
+		"src/vendor/a/b.go":      false,
+		"img/logo.png":           true,
+		"docs/intro.md":          true,
+		"docs/sub/intro.md":      false,
+		"main.go":                false,
+		"api/v1/foo/bar.pb.go":   false,
+		"other/v1/foo/bar.pb.go": true,
+		"top.go":                 true,
+		"sub/top.go":             false,
+		"api/local.json":         true,
+		"api/v1/local.json":      false,
+		"local.json":             false,
+		"gen/a.go":               false,
+	} {
+		if got := IsGeneratedByAttributes(attrFiles, name); got != want {
+			t.Errorf("IsGeneratedByAttributes(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
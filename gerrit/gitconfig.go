@@ -0,0 +1,148 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerrit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// GitConfig holds the values of a git-config style file, such as
+// project.config. It is keyed by section name (lower case), followed
+// by a '.' and the subsection name if there is one, and then by key
+// name (lower case).
+type GitConfig map[string]map[string][]string
+
+// Get returns the values of a key.
+func (c GitConfig) Get(section, subsection, key string) []string {
+	return c[sectionKey(section, subsection)][strings.ToLower(key)]
+}
+
+// Subsections returns the subsection names of a section.
+func (c GitConfig) Subsections(section string) []string {
+	var out []string
+	prefix := strings.ToLower(section) + "."
+	for k := range c {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, k[len(prefix):])
+		}
+	}
+	return out
+}
+
+func sectionKey(section, subsection string) string {
+	k := strings.ToLower(section)
+	if subsection != "" {
+		k += "." + subsection
+	}
+	return k
+}
+
+// Inherit returns the configuration c, with the keys it doesn't set
+// taken from parent. This is how Gerrit inherits project.config.
+func (c GitConfig) Inherit(parent GitConfig) GitConfig {
+	out := GitConfig{}
+	for _, cfg := range []GitConfig{parent, c} {
+		for section, keys := range cfg {
+			if out[section] == nil {
+				out[section] = map[string][]string{}
+			}
+			for k, v := range keys {
+				out[section][k] = v
+			}
+		}
+	}
+	return out
+}
+
+// ParseGitConfig parses a git-config style file. It does not
+// support includes or line continuations.
+func ParseGitConfig(content []byte) (GitConfig, error) {
+	out := GitConfig{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+
+		if l[0] == '[' {
+			end := strings.IndexByte(l, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNo)
+			}
+			header := l[1:end]
+			sub := ""
+			if i := strings.IndexByte(header, '"'); i >= 0 {
+				sub = strings.TrimSuffix(header[i+1:], `"`)
+				header = strings.TrimSpace(header[:i])
+			}
+			section = sectionKey(header, sub)
+			if out[section] == nil {
+				out[section] = map[string][]string{}
+			}
+			continue
+		}
+
+		if section == "" {
+			return nil, fmt.Errorf("line %d: key outside section", lineNo)
+		}
+		key, val := l, "true"
+		if i := strings.IndexByte(l, '='); i >= 0 {
+			key = strings.TrimSpace(l[:i])
+			var err error
+			if val, err = parseGitConfigValue(l[i+1:]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+		}
+		key = strings.ToLower(key)
+		out[section][key] = append(out[section][key], val)
+	}
+	return out, scanner.Err()
+}
+
+// parseGitConfigValue unquotes a value and strips its comment.
+func parseGitConfigValue(v string) (string, error) {
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(v):
+			i++
+			switch v[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(v[i])
+			}
+		case (c == '#' || c == ';') && !quoted:
+			return strings.TrimSpace(b.String()), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if quoted {
+		return "", fmt.Errorf("unterminated quote")
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerrit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGitConfig(t *testing.T) {
+	content := `# comment
+[project]
+	description = "a \"quoted\" value" ; comment
+[Gerrit-Linter]
+	requiredFooter = Bug
+	RequiredFooter = Change-Id
+	disableMarkers
+[gerrit-linter "java"]
+	severity = warning # comment
+`
+	cfg, err := ParseGitConfig([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		section, sub, key string
+		want              []string
+	}{
+		{"project", "", "description", []string{`a "quoted" value`}},
+		{"gerrit-linter", "", "requiredfooter", []string{"Bug", "Change-Id"}},
+		{"gerrit-linter", "", "disablemarkers", []string{"true"}},
+		{"gerrit-linter", "java", "severity", []string{"warning"}},
+		{"gerrit-linter", "Java", "severity", nil},
+	} {
+		if got := cfg.Get(tc.section, tc.sub, tc.key); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Get(%q, %q, %q) = %q, want %q", tc.section, tc.sub, tc.key, got, tc.want)
+		}
+	}
+	if got := cfg.Subsections("gerrit-linter"); !reflect.DeepEqual(got, []string{"java"}) {
+		t.Errorf("Subsections = %q", got)
+	}
+
+	for in, want := range map[string]string{
+		"key = value\n":        "outside section",
+		"[section\n":           "unterminated section",
+		"[s]\nkey = \"value\n": "unterminated quote",
+	} {
+		if _, err := ParseGitConfig([]byte(in)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseGitConfig(%q): got %v, want %q", in, err, want)
+		}
+	}
+}
+
+func TestGitConfigInherit(t *testing.T) {
+	parent := GitConfig{"s": {"a": {"1"}, "b": {"2"}}, "t": {"c": {"3"}}}
+	child := GitConfig{"s": {"b": {"4", "5"}}}
+	want := GitConfig{"s": {"a": {"1"}, "b": {"4", "5"}}, "t": {"c": {"3"}}}
+	if got := child.Inherit(parent); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -27,6 +28,23 @@ import (
 	"strings"
 )
 
+// StatusError is returned for HTTP responses with a non-2xx status.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: status %d", e.Method, e.URL, e.StatusCode)
+}
+
+// IsNotFound returns true if the error is a 404 response.
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusNotFound
+}
+
 // Server represents a single Gerrit host.
 type Server struct {
 	UserAgent string
@@ -128,7 +146,8 @@ func (g *Server) Get(u *url.URL) ([]byte, error) {
 		return nil, err
 	}
 	if rep.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("Get %s: status %d", u.String(), rep.StatusCode)
+		rep.Body.Close()
+		return nil, &StatusError{"Get", u.String(), rep.StatusCode}
 	}
 
 	defer rep.Body.Close()
@@ -188,7 +207,8 @@ func (g *Server) putPostPath(method string, pth string, contentType string, cont
 		return nil, err
 	}
 	if rep.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("%s %s: status %d", method, u.String(), rep.StatusCode)
+		rep.Body.Close()
+		return nil, &StatusError{method, u.String(), rep.StatusCode}
 	}
 
 	defer rep.Body.Close()
@@ -197,16 +217,30 @@ func (g *Server) putPostPath(method string, pth string, contentType string, cont
 
 // GetContent returns the file content from a file in a change.
 func (g *Server) GetContent(changeID string, revID string, fileID string) ([]byte, error) {
+	return g.getContent(changeID, revID, fileID, "")
+}
+
+// GetBaseContent returns the content of a file in the parent of a
+// revision.
+func (g *Server) GetBaseContent(changeID string, revID string, fileID string) ([]byte, error) {
+	return g.getContent(changeID, revID, fileID, "parent=1")
+}
+
+func (g *Server) getContent(changeID string, revID string, fileID string, query string) ([]byte, error) {
 	u := g.URL
 	path := path.Join(u.Path, fmt.Sprintf("changes/%s/revisions/%s/files/",
 		url.PathEscape(changeID), revID))
 	u.Path = path + "/" + fileID + "/content"
 	u.RawPath = path + "/" + url.PathEscape(fileID) + "/content"
+	u.RawQuery = query
 	c, err := g.Get(&u)
 	if err != nil {
 		return nil, err
 	}
+	return decodeBase64(c)
+}
 
+func decodeBase64(c []byte) ([]byte, error) {
 	dest := make([]byte, base64.StdEncoding.DecodedLen(len(c)))
 	n, err := base64.StdEncoding.Decode(dest, c)
 	if err != nil {
@@ -215,7 +249,29 @@ func (g *Server) GetContent(changeID string, revID string, fileID string) ([]byt
 	return dest[:n], nil
 }
 
-// GetChange returns the Change (including file contents) for a given change.
+// GetDiff returns the diff of a file in a revision against its
+// parent.
+func (g *Server) GetDiff(changeID string, revID string, fileID string) (*DiffInfo, error) {
+	u := g.URL
+	path := path.Join(u.Path, fmt.Sprintf("changes/%s/revisions/%s/files/",
+		url.PathEscape(changeID), revID))
+	u.Path = path + "/" + fileID + "/diff"
+	u.RawPath = path + "/" + url.PathEscape(fileID) + "/diff"
+	c, err := g.Get(&u)
+	if err != nil {
+		return nil, err
+	}
+
+	var out DiffInfo
+	if err := Unmarshal(c, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetChange returns the Change (including file contents) for a
+// given change. Deleted files are omitted. The content of binary
+// files and submodules is not fetched.
 func (g *Server) GetChange(changeID string, revID string) (*Change, error) {
 	files := map[string]*File{}
 	err := g.GetPathJSON(fmt.Sprintf("changes/%s/revisions/%s/files/",
@@ -224,8 +280,14 @@ func (g *Server) GetChange(changeID string, revID string) (*Change, error) {
 		return nil, err
 	}
 
+	deleted := map[string]*File{}
 	for name, file := range files {
-		if file.Status == "D" {
+		if file.Status == StatusDeleted {
+			deleted[name] = file
+			delete(files, name)
+			continue
+		}
+		if file.Binary || file.IsGitlink() {
 			continue
 		}
 		c, err := g.GetContent(changeID, revID, name)
@@ -235,7 +297,96 @@ func (g *Server) GetChange(changeID string, revID string) (*Change, error) {
 
 		files[name].Content = c
 	}
-	return &Change{files}, nil
+	return &Change{Files: files, Deleted: deleted}, nil
+}
+
+// GetChangeInfo returns the basic information of a change.
+func (g *Server) GetChangeInfo(changeID string) (*ChangeInfo, error) {
+	var info ChangeInfo
+	if err := g.GetPathJSON("changes/"+url.PathEscape(changeID), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetBranchContent returns the content of a file at the tip of a
+// branch.
+func (g *Server) GetBranchContent(project, branch, fileID string) ([]byte, error) {
+	u := g.URL
+	base := u.Path
+	u.Path = path.Join(base, "projects", project, "branches", branch, "files", fileID, "content")
+	u.RawPath = path.Join(base, "projects", url.PathEscape(project), "branches", url.PathEscape(branch),
+		"files", url.PathEscape(fileID), "content")
+	c, err := g.Get(&u)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBase64(c)
+}
+
+// GetProjectInfo returns the description of a project.
+func (g *Server) GetProjectInfo(project string) (*ProjectInfo, error) {
+	var info ProjectInfo
+	if err := g.getEscapedPathJSON([]string{"projects", project}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetBranchInfo returns the current revision of a branch, which may
+// be a full ref name, eg. "refs/meta/config".
+func (g *Server) GetBranchInfo(project, branch string) (*BranchInfo, error) {
+	var info BranchInfo
+	if err := g.getEscapedPathJSON([]string{"projects", project, "branches", branch}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// getEscapedPathJSON GETs the path made of the given components,
+// which are escaped individually.
+func (g *Server) getEscapedPathJSON(components []string, data interface{}) error {
+	u := g.URL
+	base := u.Path
+	escaped := make([]string, len(components))
+	for i, c := range components {
+		escaped[i] = url.PathEscape(c)
+	}
+	u.Path = path.Join(append([]string{base}, components...)...)
+	u.RawPath = path.Join(append([]string{base}, escaped...)...)
+	content, err := g.Get(&u)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(content, data)
+}
+
+// GetRevision returns the given patch set of a change, including
+// its commit and uploader.
+func (g *Server) GetRevision(changeID string, psID int) (*RevisionInfo, error) {
+	u := g.URL
+	u.Path = path.Join(u.Path, "changes", changeID)
+	u.RawQuery = "o=ALL_REVISIONS&o=ALL_COMMITS&o=DETAILED_ACCOUNTS"
+	content, err := g.Get(&u)
+	if err != nil {
+		return nil, err
+	}
+
+	var info ChangeInfo
+	if err := Unmarshal(content, &info); err != nil {
+		return nil, err
+	}
+
+	for sha1, rev := range info.Revisions {
+		if rev.Number != psID {
+			continue
+		}
+		if rev.Commit != nil && rev.Commit.Commit == "" {
+			rev.Commit.Commit = sha1
+		}
+		return rev, nil
+	}
+	return nil, fmt.Errorf("change %s has no patch set %d", changeID, psID)
 }
 
 func (s *Server) PendingChecksByScheme(scheme string) ([]*PendingChecksInfo, error) {
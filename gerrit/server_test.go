@@ -0,0 +1,111 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerrit
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newTestServer serves the given responses by escaped path. The
+// caller should close the returned httptest.Server.
+func newTestServer(t *testing.T, responses map[string][]byte) (*Server, *httptest.Server) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(*u), ts
+}
+
+func TestGetChangeFileKinds(t *testing.T) {
+	files, err := ioutil.ReadFile("testdata/files.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string]string{
+		"%2FCOMMIT_MSG":     "Add things\n\nChange-Id: I0123\n",
+		"main.go":           "package main\n",
+		"tools%2Frun":       "#!/bin/sh\necho hi\n",
+		"lib%2Fnew_name.go": "package lib\n",
+		"current":           "releases/1.0",
+	}
+	responses := map[string][]byte{
+		"/changes/123/revisions/1/files/": files,
+	}
+	for name, c := range contents {
+		responses["/changes/123/revisions/1/files/"+name+"/content"] =
+			[]byte(base64.StdEncoding.EncodeToString([]byte(c)))
+	}
+
+	g, ts := newTestServer(t, responses)
+	defer ts.Close()
+	ch, err := g.GetChange("123", "1")
+	if err != nil {
+		t.Fatalf("GetChange: %v", err)
+	}
+
+	if _, ok := ch.Files["obsolete.txt"]; ok {
+		t.Errorf("deleted file was returned")
+	}
+	if _, ok := ch.Deleted["obsolete.txt"]; !ok || len(ch.Deleted) != 1 {
+		t.Errorf("got deleted files %v, want obsolete.txt", ch.Deleted)
+	}
+
+	for name, want := range map[string]struct {
+		content            string
+		symlink, gitlink   bool
+		executable, binary bool
+		basePath           string
+	}{
+		"/COMMIT_MSG":     {content: "Add things\n\nChange-Id: I0123\n", basePath: "/COMMIT_MSG"},
+		"main.go":         {content: "package main\n", basePath: "main.go"},
+		"tools/run":       {content: "#!/bin/sh\necho hi\n", executable: true, basePath: "tools/run"},
+		"lib/new_name.go": {content: "package lib\n", basePath: "lib/old_name.go"},
+		"current":         {content: "releases/1.0", symlink: true, basePath: "current"},
+		"third_party/lib": {gitlink: true, basePath: "third_party/lib"},
+		"logo.png":        {binary: true, basePath: "logo.png"},
+	} {
+		f, ok := ch.Files[name]
+		if !ok {
+			t.Errorf("%s: missing", name)
+			continue
+		}
+		if string(f.Content) != want.content {
+			t.Errorf("%s: got content %q, want %q", name, f.Content, want.content)
+		}
+		if f.IsSymlink() != want.symlink || f.IsGitlink() != want.gitlink ||
+			f.IsExecutable() != want.executable || f.Binary != want.binary {
+			t.Errorf("%s: got kind %+v, want %+v", name, f, want)
+		}
+		if got := f.BasePath(name); got != want.basePath {
+			t.Errorf("%s: got base path %q, want %q", name, got, want.basePath)
+		}
+	}
+}
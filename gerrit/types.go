@@ -23,16 +23,67 @@ import (
 
 var jsonPrefix = []byte(")]}'")
 
+// The values of File.Status. Modified files have an empty status.
+const (
+	StatusAdded     = "A"
+	StatusDeleted   = "D"
+	StatusRenamed   = "R"
+	StatusCopied    = "C"
+	StatusRewritten = "W"
+)
+
+// The git file modes, as reported in File.OldMode and File.NewMode.
+const (
+	ModeRegular    = 0100644
+	ModeExecutable = 0100755
+	ModeSymlink    = 0120000
+	ModeGitlink    = 0160000
+)
+
 type File struct {
 	Status        string
-	LinesInserted int `json:"lines_inserted"`
-	SizeDelta     int `json:"size_delta"`
+	Binary        bool   `json:"binary"`
+	OldPath       string `json:"old_path"`
+	OldMode       int    `json:"old_mode"`
+	NewMode       int    `json:"new_mode"`
+	LinesInserted int    `json:"lines_inserted"`
+	LinesDeleted  int    `json:"lines_deleted"`
+	SizeDelta     int    `json:"size_delta"`
 	Size          int
 	Content       []byte
 }
 
+// IsSymlink returns true if the file is a symbolic link. Its content
+// is the link target.
+func (f *File) IsSymlink() bool {
+	return f.NewMode == ModeSymlink
+}
+
+// IsGitlink returns true if the file is a submodule.
+func (f *File) IsGitlink() bool {
+	return f.NewMode == ModeGitlink
+}
+
+// IsExecutable returns true if the file has the executable bit.
+func (f *File) IsExecutable() bool {
+	return f.NewMode == ModeExecutable
+}
+
+// BasePath returns the path of the file in the base revision. This
+// differs from the current path for renamed and copied files.
+func (f *File) BasePath(name string) string {
+	if f.OldPath != "" {
+		return f.OldPath
+	}
+	return name
+}
+
 type Change struct {
 	Files map[string]*File
+
+	// Deleted holds the files that the change deletes, without
+	// content.
+	Deleted map[string]*File
 }
 
 type CheckerInput struct {
@@ -164,3 +215,76 @@ type CheckInfo struct {
 	CheckerStatus string    `json:"checker_status"`
 	Blocking      []string  `json:"blocking"`
 }
+
+type AccountInfo struct {
+	AccountID int    `json:"_account_id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+}
+
+type GitPersonInfo struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  Timestamp `json:"date"`
+	TZ    int       `json:"tz"`
+}
+
+// String formats the person as "Name <email>".
+func (p *GitPersonInfo) String() string {
+	return fmt.Sprintf("%s <%s>", p.Name, p.Email)
+}
+
+type CommitInfo struct {
+	Commit    string         `json:"commit"`
+	Parents   []*CommitInfo  `json:"parents"`
+	Author    *GitPersonInfo `json:"author"`
+	Committer *GitPersonInfo `json:"committer"`
+	Subject   string         `json:"subject"`
+	Message   string         `json:"message"`
+}
+
+type RevisionInfo struct {
+	Kind     string       `json:"kind"`
+	Number   int          `json:"_number"`
+	Ref      string       `json:"ref"`
+	Uploader *AccountInfo `json:"uploader"`
+	Commit   *CommitInfo  `json:"commit"`
+}
+
+type ChangeInfo struct {
+	ID        string                   `json:"id"`
+	Project   string                   `json:"project"`
+	Branch    string                   `json:"branch"`
+	ChangeID  string                   `json:"change_id"`
+	Subject   string                   `json:"subject"`
+	Number    int                      `json:"_number"`
+	Revisions map[string]*RevisionInfo `json:"revisions"`
+}
+
+// DiffContent is a section of a DiffInfo. Lines in AB are common to
+// both sides, lines in A are only in the old, and lines in B only in
+// the new version. Skip is the number of common lines skipped.
+type DiffContent struct {
+	A    []string `json:"a"`
+	B    []string `json:"b"`
+	AB   []string `json:"ab"`
+	Skip int      `json:"skip"`
+}
+
+type DiffInfo struct {
+	ChangeType string         `json:"change_type"`
+	Content    []*DiffContent `json:"content"`
+}
+
+type ProjectInfo struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+	State  string `json:"state"`
+}
+
+type BranchInfo struct {
+	Ref      string `json:"ref"`
+	Revision string `json:"revision"`
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.md", "README.md", true},
+		{"*.md", "doc/intro.md", true},
+		{"*.md", "doc/intro.txt", false},
+		{"doc/*.md", "doc/intro.md", true},
+		{"doc/*.md", "doc/sub/intro.md", false},
+		{"doc/**/*.md", "doc/intro.md", true},
+		{"doc/**/*.md", "doc/sub/intro.md", true},
+		{"**/BUILD", "BUILD", true},
+		{"**/BUILD", "a/b/BUILD", true},
+		{"third_party/", "third_party/x/y.go", true},
+		{"third_party/", "src/third_party/y.go", false},
+		{"**/vendor/", "src/vendor/y.go", true},
+		{"Makefile", "src/Makefile", true},
+	} {
+		if got := MatchGlob(tc.pattern, tc.name); got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatchesExcluded(t *testing.T) {
+	fc := &FormatterConfig{Globs: []string{"*.md"}}
+	lc := &LanguageConfig{Exclude: []string{"third_party/", "CHANGELOG.md", "*.txt"}}
+	for _, tc := range []struct {
+		name              string
+		matches, excluded bool
+	}{
+		{"README.md", true, false},
+		{"third_party/x/README.md", false, true},
+		{"doc/CHANGELOG.md", false, true},
+		{"notes.txt", false, false},
+		{"main.go", false, false},
+	} {
+		if got := fc.Matches(tc.name, lc); got != tc.matches {
+			t.Errorf("Matches(%q) = %v, want %v", tc.name, got, tc.matches)
+		}
+		if got := fc.Excluded(tc.name, lc); got != tc.excluded {
+			t.Errorf("Excluded(%q) = %v, want %v", tc.name, got, tc.excluded)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 module github.com/google/gerrit-linter
 
 require (
-	github.com/bazelbuild/buildtools v0.0.0-20190405103555-895625218c56 // indirect
+	github.com/bazelbuild/buildtools v0.0.0-20190405103555-895625218c56
 	github.com/fsnotify/fsnotify v1.4.7 // indirect
 	github.com/golang/protobuf v1.3.1 // indirect
 	golang.org/x/sys v0.0.0-20190405154228-4b34438f7a67 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 go 1.13
@@ -0,0 +1,309 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goFormatter formats Go code in process, like gofmt.
+type goFormatter struct {
+	// simplify applies the simplifications of gofmt -s.
+	simplify bool
+}
+
+// configure accepts the gofmt flag -s, eg. "-s=false".
+func (f *goFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	out := *f
+	fs := flag.NewFlagSet("gofmt", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&out.simplify, "s", f.simplify, "simplify code")
+	if err := fs.Parse(cfg.Language(lang).Args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return &out, nil
+}
+
+func (f *goFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	var out []FormattedFile
+	for _, file := range in {
+		if file.Support {
+			continue
+		}
+		ff := FormattedFile{File: file}
+		content, err := f.format(file.Name, file.Content)
+		if list, ok := err.(scanner.ErrorList); ok {
+			ff.Findings = syntaxFindings(list)
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name, err)
+		} else {
+			ff.Content = content
+		}
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+// format formats a Go source file.
+func (f *goFormatter) format(name string, content []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if f.simplify {
+		ast.Walk(simplifier{}, file)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// syntaxFindings converts parse errors to findings.
+func syntaxFindings(list scanner.ErrorList) []Finding {
+	var out []Finding
+	for _, e := range list {
+		out = append(out, Finding{
+			Line:    e.Pos.Line,
+			Message: fmt.Sprintf("syntax error at column %d: %s", e.Pos.Column, e.Msg),
+		})
+	}
+	return out
+}
+
+// simplifier applies the simplifications of gofmt -s: it elides
+// redundant types in composite literals, "len(s)" as the high
+// bound of slice expressions on s, and blank range variables.
+type simplifier struct{}
+
+func (s simplifier) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.CompositeLit:
+		var keyType, eltType ast.Expr
+		switch typ := n.Type.(type) {
+		case *ast.ArrayType:
+			eltType = typ.Elt
+		case *ast.MapType:
+			keyType, eltType = typ.Key, typ.Value
+		}
+		if eltType == nil {
+			break
+		}
+		for i := range n.Elts {
+			px := &n.Elts[i]
+			if kv, ok := (*px).(*ast.KeyValueExpr); ok {
+				if keyType != nil {
+					s.simplifyLiteral(keyType, &kv.Key)
+				} else {
+					ast.Walk(s, kv.Key)
+				}
+				px = &kv.Value
+			}
+			s.simplifyLiteral(eltType, px)
+		}
+		return nil
+
+	case *ast.SliceExpr:
+		// s[a:len(s)] -> s[a:]
+		x, ok := n.X.(*ast.Ident)
+		if !ok || n.Max != nil {
+			break
+		}
+		call, ok := n.High.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+			break
+		}
+		if fun, ok := call.Fun.(*ast.Ident); !ok || fun.Name != "len" || fun.Obj != nil {
+			break
+		}
+		if arg, ok := call.Args[0].(*ast.Ident); ok && arg.Name == x.Name && arg.Obj == x.Obj {
+			n.High = nil
+		}
+
+	case *ast.RangeStmt:
+		// for x, _ = range v -> for x = range v
+		// for _ = range v -> for range v
+		if isBlank(n.Value) {
+			n.Value = nil
+		}
+		if n.Value == nil && isBlank(n.Key) {
+			n.Key = nil
+		}
+	}
+	return s
+}
+
+// simplifyLiteral elides the type of the composite literal *px if
+// it is typ, or *typ for &T{} literals.
+func (s simplifier) simplifyLiteral(typ ast.Expr, px *ast.Expr) {
+	ast.Walk(s, *px)
+	switch x := (*px).(type) {
+	case *ast.CompositeLit:
+		if x.Type != nil && types.ExprString(x.Type) == types.ExprString(typ) {
+			x.Type = nil
+		}
+	case *ast.UnaryExpr:
+		ptr, ok := typ.(*ast.StarExpr)
+		if !ok || x.Op != token.AND {
+			break
+		}
+		if lit, ok := x.X.(*ast.CompositeLit); ok && lit.Type != nil &&
+			types.ExprString(lit.Type) == types.ExprString(ptr.X) {
+			lit.Type = nil
+			*px = lit
+		}
+	}
+}
+
+func isBlank(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// goImportsFormatter groups imports like goimports: within each
+// group of imports not separated by blank lines, standard library
+// packages come first, followed by a blank line and the other
+// packages. Unlike goimports, it does not add or remove imports.
+// Files that do not parse are left to goFormatter.
+type goImportsFormatter struct{}
+
+func (f *goImportsFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	var out []FormattedFile
+	for _, file := range in {
+		if file.Support {
+			continue
+		}
+		ff := FormattedFile{File: file}
+		if content, err := groupImports(file.Name, file.Content); err == nil {
+			ff.Content = content
+		}
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+// groupImports separates standard library imports from the others.
+// Groups with comments on lines of their own, including doc
+// comments, are left alone.
+func groupImports(name string, content []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, content, parser.ParseComments|parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+
+	// Lines that hold only a comment.
+	commentLines := map[int]bool{}
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			commentLines[line(c.Pos())] = true
+		}
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	var replacements []struct {
+		start, end int
+		text       string
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT || !gen.Lparen.IsValid() {
+			continue
+		}
+		specs := gen.Specs
+		for len(specs) > 0 {
+			n := 1
+			for n < len(specs) && line(specs[n].Pos()) <= line(specs[n-1].End())+1 {
+				n++
+			}
+			group := specs[:n]
+			specs = specs[n:]
+
+			start, end := line(group[0].Pos()), line(group[n-1].End())
+			if end-start+1 != n {
+				continue
+			}
+			specLines := map[int]bool{}
+			hasComment := false
+			for _, s := range group {
+				specLines[line(s.Pos())] = true
+				hasComment = hasComment || s.(*ast.ImportSpec).Doc != nil
+			}
+			for l := start; l <= end; l++ {
+				hasComment = hasComment || commentLines[l] && !specLines[l]
+			}
+			if hasComment {
+				continue
+			}
+
+			type entry struct {
+				std  bool
+				path string
+				text string
+			}
+			var entries []entry
+			for _, s := range group {
+				p, _ := strconv.Unquote(s.(*ast.ImportSpec).Path.Value)
+				entries = append(entries, entry{
+					std:  !strings.Contains(strings.SplitN(p, "/", 2)[0], "."),
+					path: p,
+					text: lines[line(s.Pos())-1],
+				})
+			}
+			sort.SliceStable(entries, func(i, j int) bool {
+				if entries[i].std != entries[j].std {
+					return entries[i].std
+				}
+				return entries[i].path < entries[j].path
+			})
+			var b strings.Builder
+			for i, e := range entries {
+				if i > 0 && e.std != entries[i-1].std {
+					b.WriteString("\n")
+				}
+				b.WriteString(e.text)
+			}
+			replacements = append(replacements, struct {
+				start, end int
+				text       string
+			}{start, end, b.String()})
+		}
+	}
+
+	for i := len(replacements) - 1; i >= 0; i-- {
+		r := replacements[i]
+		lines = append(append(lines[:r.start-1:r.start-1], r.text), lines[r.end:]...)
+	}
+	return format.Source([]byte(strings.Join(lines, "")))
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoFormatter(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		simplify bool
+	}{
+		{"package a\nvar  x=1\n", "package a\n\nvar x = 1\n", false},
+		{"package a\n\nvar s = []T{T{1}}\n", "package a\n\nvar s = []T{T{1}}\n", false},
+		{"package a\n\nvar s = []T{T{1}}\n", "package a\n\nvar s = []T{{1}}\n", true},
+		{"package a\n\nvar s = []*T{&T{1}}\n", "package a\n\nvar s = []*T{{1}}\n", true},
+		{"package a\n\nvar m = map[K]V{K{1}: V{2}}\n", "package a\n\nvar m = map[K]V{{1}: {2}}\n", true},
+		{"package a\n\nvar s = []U{T{1}}\n", "package a\n\nvar s = []U{T{1}}\n", true},
+		{"package a\n\nfunc f(s []int) []int { return s[1:len(s)] }\n",
+			"package a\n\nfunc f(s []int) []int { return s[1:] }\n", true},
+		{"package a\n\nfunc f(s []int) {\n\tfor i, _ := range s {\n\t\t_ = i\n\t}\n\tfor _ = range s {\n\t}\n}\n",
+			"package a\n\nfunc f(s []int) {\n\tfor i := range s {\n\t\t_ = i\n\t}\n\tfor range s {\n\t}\n}\n", true},
+	} {
+		f := &goFormatter{simplify: tc.simplify}
+		got, err := f.format("a.go", []byte(tc.in))
+		if err != nil {
+			t.Errorf("format(%q): %v", tc.in, err)
+		} else if string(got) != tc.want {
+			t.Errorf("format(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestGoFormatterSyntaxError(t *testing.T) {
+	f := &goFormatter{}
+	out, err := f.Format([]File{{Name: "a.go", Content: []byte("package a\n\nfunc f() {\n\tx := \n}\n")}}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out[0].Findings) == 0 {
+		t.Fatal("got no findings")
+	}
+	if got := out[0].Findings[0]; got.Line != 5 || !strings.Contains(got.Message, "syntax error at column 1") {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestGoFormatterConfigure(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		want    bool
+		wantErr string
+	}{
+		{nil, true, ""},
+		{[]string{"-s=false"}, false, ""},
+		{[]string{"-x"}, false, "not defined"},
+		{[]string{"-s", "a.go"}, false, "unexpected argument"},
+	} {
+		cfg := &Config{Languages: map[string]*LanguageConfig{"go": {Args: tc.args}}}
+		f, err := (&goFormatter{simplify: true}).configure(cfg, "go")
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%q: got error %v, want %q", tc.args, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.args, err)
+		} else if got := f.(*goFormatter).simplify; got != tc.want {
+			t.Errorf("%q: got simplify %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestGroupImports(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"package a\n\nimport (\n\t\"fmt\"\n\t\"github.com/x/y\"\n\t\"os\"\n)\n",
+			"package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"github.com/x/y\"\n)\n"},
+		{"package a\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/x/y\"\n)\n",
+			"package a\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/x/y\"\n)\n"},
+		{"package a\n\nimport (\n\ty \"github.com/x/y\" // y\n\t\"os\"\n)\n",
+			"package a\n\nimport (\n\t\"os\"\n\n\ty \"github.com/x/y\" // y\n)\n"},
+		{"package a\n\nimport (\n\t// x\n\t\"github.com/x/y\"\n\t\"os\"\n)\n",
+			"package a\n\nimport (\n\t// x\n\t\"github.com/x/y\"\n\t\"os\"\n)\n"},
+	} {
+		got, err := groupImports("a.go", []byte(tc.in))
+		if err != nil {
+			t.Errorf("groupImports(%q): %v", tc.in, err)
+		} else if string(got) != tc.want {
+			t.Errorf("groupImports(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNativeGoPipeline(t *testing.T) {
+	req := &FormatRequest{Files: []File{{
+		Language: "go",
+		Name:     "a.go",
+		Content:  []byte("package a\n\nimport (\n\t\"github.com/x/y\"\n\t\"os\"\n)\n\nvar s = []T{T{1}}\n"),
+	}}}
+	rep := &FormatReply{}
+	if err := Format(req, rep); err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{Line: 8, Message: "found a difference", Stage: "gofmt"},
+		{Line: 4, Message: "found a difference", Stage: "goimports"},
+		{Line: 6, Message: "found a difference", Stage: "goimports"},
+	}
+	if got := rep.Files[0].Findings; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
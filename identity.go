@@ -0,0 +1,201 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// IdentityPolicy describes which author and committer identities
+// are acceptable.
+type IdentityPolicy struct {
+	// AllowedDomains, if non-empty, lists the email domains that
+	// may be used. Subdomains of an allowed domain are allowed too.
+	AllowedDomains []string `json:"allowed_domains,omitempty" yaml:"allowed_domains,omitempty"`
+
+	// DeniedDomains lists email domains that may not be used, in
+	// addition to machine-local ones such as "localhost".
+	DeniedDomains []string `json:"denied_domains,omitempty" yaml:"denied_domains,omitempty"`
+
+	// NamePattern is a regular expression that names must match.
+	NamePattern string `json:"name_pattern,omitempty" yaml:"name_pattern,omitempty"`
+
+	// CommitterIsUploader requires the committer email to be the
+	// email of the account that uploaded the patch set.
+	CommitterIsUploader bool `json:"committer_is_uploader,omitempty" yaml:"committer_is_uploader,omitempty"`
+}
+
+// machineDomains are the domains that misconfigured machines put
+// in identities.
+var machineDomains = []string{"localhost", "localdomain", "local", "(none)"}
+
+// The keys of the header that Gerrit shows above the commit
+// message. Uploader is not shown by Gerrit, but is added by the
+// checker.
+const (
+	authorKey    = "Author"
+	committerKey = "Commit"
+	uploaderKey  = "Uploader"
+)
+
+// CommitHeader formats the identities of a commit the way Gerrit
+// shows them above the commit message. Empty identities are
+// omitted.
+func CommitHeader(author, committer, uploader string) string {
+	var b strings.Builder
+	for _, kv := range [][2]string{
+		{authorKey, author},
+		{committerKey, committer},
+		{uploaderKey, uploader},
+	} {
+		if kv[1] != "" {
+			fmt.Fprintf(&b, "%-12s%s\n", kv[0]+":", kv[1])
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+type identityFormatter struct {
+	policy      IdentityPolicy
+	namePattern *regexp.Regexp
+}
+
+func (f *identityFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	out := &identityFormatter{}
+	if cfg == nil || cfg.Identity == nil {
+		return out, nil
+	}
+	out.policy = *cfg.Identity
+	if out.policy.NamePattern != "" {
+		re, err := regexp.Compile(out.policy.NamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("identity name_pattern: %v", err)
+		}
+		out.namePattern = re
+	}
+	return out, nil
+}
+
+func (f *identityFormatter) Format(in []File, outSink io.Writer) (out []FormattedFile, err error) {
+	ff := FormattedFile{}
+	ff.Name = in[0].Name
+	ff.Content = in[0].Content
+	ff.Findings = f.check(string(in[0].Content))
+	out = append(out, ff)
+	return out, nil
+}
+
+var personRegex = regexp.MustCompile(`^(.*?)\s*<([^<>]*)>$`)
+
+// check returns the findings for a commit message that starts with
+// a CommitHeader.
+func (f *identityFormatter) check(msg string) []Finding {
+	header := map[string]string{}
+	lineNo := map[string]int{}
+	for i, l := range strings.Split(msg, "\n") {
+		if l == "" {
+			break
+		}
+		fields := strings.SplitN(l, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		header[fields[0]] = strings.TrimSpace(fields[1])
+		lineNo[fields[0]] = i + 1
+	}
+
+	var out []Finding
+	emails := map[string]string{}
+	for _, key := range []string{authorKey, committerKey} {
+		val, ok := header[key]
+		if !ok {
+			out = append(out, Finding{Message: fmt.Sprintf("%s identity not found", strings.ToLower(key))})
+			continue
+		}
+		email, complaint := f.checkPerson(val)
+		emails[key] = email
+		if complaint != "" {
+			out = append(out, Finding{
+				Line:    lineNo[key],
+				Message: fmt.Sprintf("%s %q: %s", strings.ToLower(key), val, complaint),
+			})
+		}
+	}
+
+	if f.policy.CommitterIsUploader {
+		uploader, ok := header[uploaderKey]
+		m := personRegex.FindStringSubmatch(uploader)
+		if !ok || m == nil {
+			out = append(out, Finding{Message: "uploader identity not found"})
+		} else if committer := emails[committerKey]; committer != "" && !strings.EqualFold(committer, m[2]) {
+			out = append(out, Finding{
+				Line:    lineNo[committerKey],
+				Message: fmt.Sprintf("committer %q must be the uploader %q", committer, m[2]),
+			})
+		}
+	}
+	return out
+}
+
+// checkPerson checks a "Name <email>" identity. It returns the
+// email, and a complaint if the identity violates the policy.
+func (f *identityFormatter) checkPerson(person string) (email string, complaint string) {
+	m := personRegex.FindStringSubmatch(person)
+	if m == nil {
+		return "", "must have the form 'Name <email>'"
+	}
+	name, email := m[1], m[2]
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email, "email must have the form 'user@domain'"
+	}
+	domain := strings.ToLower(email[at+1:])
+	if matchDomain(domain, machineDomains) || !strings.Contains(domain, ".") {
+		return email, fmt.Sprintf("domain %q is machine-local", domain)
+	}
+	if matchDomain(domain, f.policy.DeniedDomains) {
+		return email, fmt.Sprintf("domain %q is denied", domain)
+	}
+	if len(f.policy.AllowedDomains) > 0 && !matchDomain(domain, f.policy.AllowedDomains) {
+		return email, fmt.Sprintf("domain %q is not one of %s", domain,
+			strings.Join(f.policy.AllowedDomains, ", "))
+	}
+
+	if name == "" {
+		return email, "name must be non-empty"
+	}
+	if f.namePattern != nil && !f.namePattern.MatchString(name) {
+		return email, fmt.Sprintf("name must match %q", f.policy.NamePattern)
+	}
+
+	return email, ""
+}
+
+// matchDomain returns true if domain is one of the given domains,
+// or a subdomain of one of them.
+func matchDomain(domain string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
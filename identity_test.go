@@ -0,0 +1,60 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdentity(t *testing.T) {
+	policy := &IdentityPolicy{
+		AllowedDomains:      []string{"example.com"},
+		DeniedDomains:       []string{"old.example.com"},
+		NamePattern:         `^\S+ \S+`,
+		CommitterIsUploader: true,
+	}
+	fmtr, err := (&identityFormatter{}).configure(&Config{Identity: policy}, "identity")
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	f := fmtr.(*identityFormatter)
+
+	good := "A U <au@example.com>"
+	for in, want := range map[string]string{
+		CommitHeader(good, good, good):                                           "",
+		CommitHeader(good, "A U <au@eng.example.com>", "X <au@eng.example.com>"): "",
+		CommitHeader("root <root@localhost>", good, good):                        "machine-local",
+		CommitHeader("A U <au@box.localdomain>", good, good):                     "machine-local",
+		CommitHeader("A U <au@old.example.com>", good, good):                     "denied",
+		CommitHeader("A U <au@gmail.com>", good, good):                           "not one of",
+		CommitHeader("au <au@example.com>", good, good):                          "must match",
+		CommitHeader("A U au@example.com", good, good):                           "form 'Name <email>'",
+		CommitHeader(good, good, "B V <bv@example.com>"):                         "must be the uploader",
+		CommitHeader(good, good, ""):                                             "uploader identity not found",
+		"subject\n\nbody\n":                                                      "author identity not found",
+	} {
+		var got []string
+		for _, finding := range f.check(in + "subject\n") {
+			got = append(got, finding.Message)
+		}
+		gotStr := strings.Join(got, "; ")
+		if want == "" && gotStr != "" {
+			t.Errorf("%q: want empty, got %s", in, gotStr)
+		} else if !strings.Contains(gotStr, want) {
+			t.Errorf("%q: got %s, want substring %s", in, gotStr, want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// severityRanks orders the severities of findings.
+var severityRanks = map[string]int{
+	SeverityInfo:    1,
+	SeverityWarning: 2,
+	SeverityError:   3,
+}
+
+// Fails returns true if the finding is at least as severe as
+// failOn, which is a LanguageConfig.FailOn value. Findings without
+// a severity are errors.
+func (f *Finding) Fails(failOn string) bool {
+	sev, threshold := f.Severity, failOn
+	if sev == "" {
+		sev = SeverityError
+	}
+	if threshold == "" {
+		threshold = SeverityError
+	}
+	return severityRanks[sev] >= severityRanks[threshold]
+}
+
+// normalizeSeverity maps the severity names used by tools to
+// SeverityError, SeverityWarning or SeverityInfo, using the
+// severities map first. Unknown names are errors.
+func normalizeSeverity(severities map[string]string, s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if m, ok := severities[s]; ok {
+		return m
+	}
+	switch s {
+	case "warning", "warn", "w":
+		return SeverityWarning
+	case "info", "information", "note", "style", "convention", "refactor", "hint", "i", "c", "r":
+		return SeverityInfo
+	}
+	return SeverityError
+}
+
+// JSONMapping describes how to read findings from the JSON output
+// of a linter. Fields are dotted paths, eg. "location.row".
+type JSONMapping struct {
+	// Items is the path of the list of diagnostics. If empty, the
+	// output is the list.
+	Items string `json:"items,omitempty"`
+
+	// The paths of the diagnostic fields. Line defaults to
+	// "line", and Message to "message". Others are optional.
+	File     string `json:"file,omitempty"`
+	Line     string `json:"line,omitempty"`
+	Column   string `json:"column,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Code     string `json:"code,omitempty"`
+
+	// CodePrefix is prepended to codes, eg. "SC" for the numeric
+	// codes of shellcheck.
+	CodePrefix string `json:"code_prefix,omitempty"`
+
+	// CodeURL is a format string for the documentation of a code,
+	// eg. "https://www.shellcheck.net/wiki/%s".
+	CodeURL string `json:"code_url,omitempty"`
+}
+
+// jsonPath returns the value at the dotted path in v, or nil.
+func jsonPath(v interface{}, path string) interface{} {
+	if path == "" {
+		return v
+	}
+	for _, k := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// jsonString returns the value at path as a string.
+func jsonString(v interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	switch x := jsonPath(v, path).(type) {
+	case string:
+		return x
+	case float64:
+		return fmt.Sprintf("%d", int(x))
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// parse returns the diagnostics in output as the named groups of a
+// problem matcher.
+func (m *JSONMapping) parse(output []byte) ([]map[string]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(output, &v); err != nil {
+		return nil, err
+	}
+	items, ok := jsonPath(v, m.Items).([]interface{})
+	if !ok && jsonPath(v, m.Items) != nil {
+		return nil, fmt.Errorf("%q is not a list", m.Items)
+	}
+	lineKey, msgKey := m.Line, m.Message
+	if lineKey == "" {
+		lineKey = "line"
+	}
+	if msgKey == "" {
+		msgKey = "message"
+	}
+	var out []map[string]string
+	for _, it := range items {
+		p := map[string]string{
+			"file":     jsonString(it, m.File),
+			"line":     jsonString(it, lineKey),
+			"col":      jsonString(it, m.Column),
+			"message":  jsonString(it, msgKey),
+			"severity": jsonString(it, m.Severity),
+		}
+		if code := jsonString(it, m.Code); code != "" {
+			p["code"] = m.CodePrefix + code
+			if m.CodeURL != "" {
+				p["url"] = fmt.Sprintf(m.CodeURL, p["code"])
+			}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// lintToolFormatter runs a linter, which reports problems rather
+// than rewriting files. Its output is parsed into findings, with
+// problem matchers, or a JSONMapping if the tool prints JSON. The
+// content is returned unchanged, so a file passes if its findings
+// are below the LanguageConfig.FailOn threshold.
+type lintToolFormatter struct {
+	bin  string
+	args []string
+
+	// matchers parse the output lines. If nil,
+	// defaultProblemMatchers are used.
+	matchers []*regexp.Regexp
+
+	// json, if set, parses the output as JSON instead.
+	json *JSONMapping
+
+	// severities maps the tool's severity names to ours, eg.
+	// "style" to SeverityInfo.
+	severities map[string]string
+}
+
+func (f *lintToolFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	args := cfg.Language(lang).Args
+	if len(args) == 0 {
+		return f, nil
+	}
+	out := *f
+	out.args = append(append([]string{}, f.args...), args...)
+	return &out, nil
+}
+
+func (f *lintToolFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	tmpDir, err := ioutil.TempDir("", "gerritlint")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := writeFiles(tmpDir, in); err != nil {
+		return nil, err
+	}
+
+	var out []FormattedFile
+	for _, file := range in {
+		if file.Support {
+			continue
+		}
+		// Files are linted one by one, so problems without a
+		// file name can be attributed.
+		output, err := f.run(tmpDir, file.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name, err)
+		}
+		out = append(out, FormattedFile{File: file, Findings: output})
+	}
+	return out, nil
+}
+
+// run lints one file in dir, and returns its findings.
+func (f *lintToolFormatter) run(dir, name string) ([]Finding, error) {
+	cmd := exec.Command(f.bin, f.args...)
+	cmd.Args = append(cmd.Args, name)
+	cmd.Dir = dir
+
+	var errBuf, outBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	log.Println("running", cmd.Args, "in", dir)
+	runErr := cmd.Run()
+	if _, ok := runErr.(*exec.ExitError); runErr != nil && !ok {
+		return nil, runErr
+	}
+
+	var problems []map[string]string
+	if f.json != nil {
+		if runErr == nil || outBuf.Len() > 0 {
+			var err error
+			if problems, err = f.json.parse(outBuf.Bytes()); err != nil {
+				return nil, fmt.Errorf("parsing output: %v", err)
+			}
+		}
+	} else {
+		matchers := f.matchers
+		if matchers == nil {
+			matchers = defaultProblemMatchers
+		}
+		for _, l := range strings.Split(outBuf.String()+errBuf.String(), "\n") {
+			l = strings.TrimRight(l, "\r")
+			for _, re := range matchers {
+				if m := matchProblem(re, l); m != nil {
+					problems = append(problems, m)
+					break
+				}
+			}
+		}
+	}
+
+	findings := problemFindings(problems, name, f.severities)
+	if runErr != nil && len(findings) == 0 {
+		// Linters fail when they find problems, so failing
+		// without reporting any is an error.
+		return nil, &toolError{err: runErr, stderr: errBuf.String(), stdout: outBuf.String()}
+	}
+	return findings, nil
+}
+
+// writeFiles writes the files under dir.
+func writeFiles(dir string, files []File) error {
+	for _, f := range files {
+		name := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(name, f.Content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestFindingFails(t *testing.T) {
+	for _, tc := range []struct {
+		severity, failOn string
+		want             bool
+	}{
+		{"", "", true},
+		{SeverityWarning, "", false},
+		{SeverityWarning, SeverityWarning, true},
+		{SeverityInfo, SeverityWarning, false},
+		{SeverityInfo, SeverityInfo, true},
+		{SeverityError, SeverityInfo, true},
+	} {
+		f := Finding{Severity: tc.severity}
+		if got := f.Fails(tc.failOn); got != tc.want {
+			t.Errorf("Finding{Severity: %q}.Fails(%q) = %v, want %v", tc.severity, tc.failOn, got, tc.want)
+		}
+	}
+}
+
+func TestLintToolFormatter(t *testing.T) {
+	content := []byte("echo $x\n")
+	for _, tc := range []struct {
+		name   string
+		f      *lintToolFormatter
+		output string
+		want   []Finding
+	}{
+		{
+			name: "matchers",
+			f: &lintToolFormatter{matchers: []*regexp.Regexp{
+				regexp.MustCompile(`^(?P<file>[^:]+):(?P<line>\d+):(?P<col>\d+): (?P<severity>\w+) (?P<code>\w+): (?P<message>.*)$`),
+			}},
+			output: "a.sh:1:6: note SC2086: Double quote to prevent globbing.\nb.sh:2:1: error SC1000: other file.",
+			want:   []Finding{{Line: 1, Message: "column 6: SC2086: Double quote to prevent globbing.", Severity: SeverityInfo}},
+		},
+		{
+			name: "json",
+			f: &lintToolFormatter{
+				json: &JSONMapping{
+					File:     "file",
+					Line:     "location.row",
+					Severity: "level",
+					Code:     "code",
+				},
+				severities: map[string]string{"style": SeverityWarning},
+			},
+			output: `[{"file": "a.sh", "location": {"row": 1}, "level": "style", "code": "SC2086", "message": "quote"},
+			{"file": "a.sh", "location": {"row": 2}, "message": "bad"}]`,
+			want: []Finding{
+				{Line: 1, Message: "SC2086: quote", Severity: SeverityWarning},
+				{Line: 2, Message: "bad"},
+			},
+		},
+		{
+			name:   "json items",
+			f:      &lintToolFormatter{json: &JSONMapping{Items: "results"}},
+			output: `{"results": [{"line": 3, "message": "x"}]}`,
+			want:   []Finding{{Line: 3, Message: "x"}},
+		},
+	} {
+		// Prints the output, and fails like linters do.
+		tc.f.bin = "sh"
+		tc.f.args = []string{"-c", "printf '%s' '" + tc.output + "'; exit 1", "sh"}
+		out, err := tc.f.Format([]File{{Name: "a.sh", Content: content}}, ioutil.Discard)
+		if err != nil {
+			t.Errorf("%s: Format: %v", tc.name, err)
+			continue
+		}
+		want := []FormattedFile{{File: File{Name: "a.sh", Content: content}, Findings: tc.want}}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("%s: got %v, want %v", tc.name, out, want)
+		}
+	}
+
+	f := &lintToolFormatter{bin: "sh", args: []string{"-c", "echo crashed; exit 2", "sh"}}
+	if _, err := f.Format([]File{{Name: "a.sh", Content: content}}, ioutil.Discard); err == nil {
+		t.Errorf("got no error for failure without findings")
+	}
+	f = &lintToolFormatter{bin: "true"}
+	if out, err := f.Format([]File{{Name: "a.sh", Content: content}}, ioutil.Discard); err != nil || len(out[0].Findings) != 0 {
+		t.Errorf("got %v, %v for clean file", out, err)
+	}
+}
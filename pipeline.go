@@ -0,0 +1,259 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// stage is a named step of a pipelineFormatter.
+type stage struct {
+	name      string
+	formatter Formatter
+}
+
+// pipelineFormatter runs formatters and linters in order. The
+// content formatted by a stage is the input of the next one, and
+// findings are merged, with their lines mapped back to the
+// original content. Differences introduced by a stage that does
+// not explain them with findings are reported as findings, one per
+// hunk, so each says which stage produced it.
+type pipelineFormatter struct {
+	stages []stage
+}
+
+// configure sets up the stages for the configuration, and drops
+// those listed in LanguageConfig.SkipStages, and optional stages
+// whose configure returns a nil Formatter. LanguageConfig.Args only
+// apply to the first stage.
+func (p *pipelineFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	lc := cfg.Language(lang)
+	for _, s := range lc.SkipStages {
+		found := false
+		for _, st := range p.stages {
+			found = found || st.name == s
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown stage %q", s)
+		}
+	}
+
+	out := &pipelineFormatter{}
+	for _, st := range p.stages {
+		skip := false
+		for _, s := range lc.SkipStages {
+			skip = skip || st.name == s
+		}
+		if skip {
+			continue
+		}
+		stageCfg := cfg
+		if len(out.stages) > 0 {
+			stageCfg = withoutArgs(cfg, lang)
+		}
+		f := st.formatter
+		if c, ok := f.(configurable); ok {
+			var err error
+			if f, err = c.configure(stageCfg, lang); err != nil {
+				return nil, fmt.Errorf("%s: %v", st.name, err)
+			}
+			if f == nil {
+				continue
+			}
+		}
+		out.stages = append(out.stages, stage{st.name, f})
+	}
+	return out, nil
+}
+
+// withoutArgs returns cfg without the arguments for lang.
+func withoutArgs(cfg *Config, lang string) *Config {
+	if len(cfg.Language(lang).Args) == 0 {
+		return cfg
+	}
+	out := *cfg
+	out.Languages = map[string]*LanguageConfig{}
+	for l, lc := range cfg.Languages {
+		out.Languages[l] = lc
+	}
+	lc := *cfg.Language(lang)
+	lc.Args = nil
+	out.Languages[lang] = &lc
+	return &out
+}
+
+func (p *pipelineFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	type pipelineFile struct {
+		orig []byte
+		out  FormattedFile
+	}
+	var support []File
+	var files []*pipelineFile
+	for _, f := range in {
+		if f.Support {
+			support = append(support, f)
+		} else {
+			files = append(files, &pipelineFile{orig: f.Content, out: FormattedFile{File: f}})
+		}
+	}
+
+	for _, st := range p.stages {
+		byName := map[string]*pipelineFile{}
+		var input []File
+		for _, pf := range files {
+			byName[pf.out.Name] = pf
+			input = append(input, pf.out.File)
+		}
+		out, err := st.formatter.Format(append(input, support...), outSink)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", st.name, err)
+		}
+
+		for _, o := range out {
+			pf, ok := byName[o.Name]
+			if !ok {
+				continue
+			}
+			prev := pf.out.Content
+			toOrig := invertHunks(Diff(pf.orig, prev))
+			for _, finding := range o.Findings {
+				if finding.Stage == "" {
+					finding.Stage = st.name
+				}
+				if finding.Line > 0 {
+					finding.Line = MapLine(toOrig, finding.Line)
+				}
+				pf.out.Findings = append(pf.out.Findings, finding)
+			}
+			if o.Message != "" {
+				pf.out.Message = o.Message
+			}
+			if bytes.Equal(prev, o.Content) {
+				continue
+			}
+
+			hunks := Diff(prev, o.Content)
+			if len(o.Findings) == 0 {
+				for _, h := range hunks {
+					pf.out.Findings = append(pf.out.Findings, Finding{
+						Line:    MapLine(toOrig, h.A.Start),
+						Message: "found a difference",
+						Stage:   st.name,
+					})
+				}
+			}
+			for i, r := range pf.out.Lines {
+				pf.out.Lines[i] = MapRange(hunks, r)
+			}
+			pf.out.Content = o.Content
+		}
+	}
+
+	var out []FormattedFile
+	for _, pf := range files {
+		out = append(out, pf.out)
+	}
+	return out, nil
+}
+
+// lineLengthLinter reports lines longer than
+// LanguageConfig.MaxLineLength characters, if it is set.
+type lineLengthLinter struct {
+	max int
+}
+
+func (l *lineLengthLinter) configure(cfg *Config, lang string) (Formatter, error) {
+	max := cfg.Language(lang).MaxLineLength
+	if max < 0 {
+		return nil, fmt.Errorf("max_line_length must be positive")
+	}
+	return &lineLengthLinter{max: max}, nil
+}
+
+func (l *lineLengthLinter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	var out []FormattedFile
+	for _, f := range in {
+		if f.Support {
+			continue
+		}
+		ff := FormattedFile{File: f}
+		if l.max > 0 {
+			for i, line := range strings.Split(string(f.Content), "\n") {
+				line = strings.TrimSuffix(line, "\r")
+				if n := utf8.RuneCountInString(line); n > l.max {
+					ff.Findings = append(ff.Findings, Finding{
+						Line:    i + 1,
+						Message: fmt.Sprintf("line is %d characters, more than %d", n, l.max),
+					})
+				}
+			}
+		}
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+// javaImportRegex matches a Java import statement.
+var javaImportRegex = regexp.MustCompile(`^import\s+(static\s+)?([\w.*]+)\s*;`)
+
+// javaImportOrderLinter checks that Java imports are ordered as
+// google-java-format orders them: static imports first, and each
+// group in ASCII order.
+type javaImportOrderLinter struct{}
+
+func (l *javaImportOrderLinter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	var out []FormattedFile
+	for _, f := range in {
+		if f.Support {
+			continue
+		}
+		ff := FormattedFile{File: f}
+		ff.Findings = checkJavaImportOrder(f.Content)
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+// checkJavaImportOrder returns findings for misordered imports.
+func checkJavaImportOrder(content []byte) []Finding {
+	var findings []Finding
+	seenNonStatic := false
+	last := map[bool]string{}
+	for i, line := range strings.Split(string(content), "\n") {
+		m := javaImportRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		static, name := m[1] != "", m[2]
+		if static && seenNonStatic {
+			findings = append(findings, Finding{Line: i + 1,
+				Message: fmt.Sprintf("static import %s must come before non-static imports", name)})
+		}
+		if prev := last[static]; prev != "" && name < prev {
+			findings = append(findings, Finding{Line: i + 1,
+				Message: fmt.Sprintf("import %s must come before %s", name, prev)})
+		}
+		if name > last[static] {
+			last[static] = name
+		}
+		seenNonStatic = seenNonStatic || !static
+	}
+	return findings
+}
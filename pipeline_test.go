@@ -0,0 +1,147 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// funcFormatter formats each file with a function.
+type funcFormatter func(f File) FormattedFile
+
+func (fn funcFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	var out []FormattedFile
+	for _, f := range in {
+		if !f.Support {
+			out = append(out, fn(f))
+		}
+	}
+	return out, nil
+}
+
+func TestPipeline(t *testing.T) {
+	header := funcFormatter(func(f File) FormattedFile {
+		out := FormattedFile{File: f}
+		out.Content = append([]byte("// header\n"), f.Content...)
+		return out
+	})
+	noX := funcFormatter(func(f File) FormattedFile {
+		out := FormattedFile{File: f}
+		for i, l := range strings.Split(string(f.Content), "\n") {
+			if strings.Contains(l, "x") {
+				out.Findings = append(out.Findings, Finding{Line: i + 1, Message: "has x"})
+			}
+		}
+		return out
+	})
+	p := &pipelineFormatter{stages: []stage{{"header", header}, {"no-x", noX}}}
+
+	out, err := p.Format([]File{
+		{Name: "a.txt", Content: []byte("a\nx\nb\n"), Lines: []LineRange{{Start: 2, End: 3}}},
+		{Name: "cfg", Content: []byte("x\n"), Support: true},
+	}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("got %d files, want 1", len(out))
+	}
+	if got, want := string(out[0].Content), "// header\na\nx\nb\n"; got != want {
+		t.Errorf("got content %q, want %q", got, want)
+	}
+	want := []Finding{
+		{Line: 1, Message: "found a difference", Stage: "header"},
+		{Line: 2, Message: "has x", Stage: "no-x"},
+	}
+	if !reflect.DeepEqual(out[0].Findings, want) {
+		t.Errorf("got findings %+v, want %+v", out[0].Findings, want)
+	}
+	if got, want := out[0].Lines, []LineRange{{Start: 3, End: 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %v, want %v", got, want)
+	}
+}
+
+func TestPipelineConfigure(t *testing.T) {
+	p := &pipelineFormatter{stages: []stage{
+		{"first", &toolFormatter{bin: "a"}},
+		{"second", &toolFormatter{bin: "b"}},
+		{"line-length", &lineLengthLinter{}},
+	}}
+	cfg := &Config{Languages: map[string]*LanguageConfig{
+		"x": {Args: []string{"--flag"}, SkipStages: []string{"second"}, MaxLineLength: 80},
+	}}
+	f, err := p.configure(cfg, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := f.(*pipelineFormatter).stages
+	if len(got) != 2 || got[0].name != "first" || got[1].name != "line-length" {
+		t.Fatalf("got stages %+v", got)
+	}
+	if args := got[0].formatter.(*toolFormatter).args; !reflect.DeepEqual(args, []string{"--flag"}) {
+		t.Errorf("got args %q", args)
+	}
+	if max := got[1].formatter.(*lineLengthLinter).max; max != 80 {
+		t.Errorf("got max %d", max)
+	}
+
+	cfg.Languages["x"].SkipStages = []string{"third"}
+	if _, err := p.configure(cfg, "x"); err == nil || !strings.Contains(err.Error(), "unknown stage") {
+		t.Errorf("got %v, want unknown stage", err)
+	}
+}
+
+func TestGoPipeline(t *testing.T) {
+	req := &FormatRequest{
+		Files: []File{{
+			Language: "go",
+			Name:     "a.go",
+			Content:  []byte("package a\n\nvar s = []T{T{1}}\n\n// " + strings.Repeat("x", 30) + "\n"),
+		}},
+		Config: &Config{Languages: map[string]*LanguageConfig{"go": {MaxLineLength: 30}}},
+	}
+	rep := &FormatReply{}
+	if err := Format(req, rep); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range rep.Files[0].Findings {
+		got = append(got, f.Stage)
+	}
+	if want := []string{"gofmt", "line-length"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got stages %q, want %q", got, want)
+	}
+}
+
+func TestCheckJavaImportOrder(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []Finding
+	}{
+		{"import static a.B.c;\n\nimport a.B;\nimport b.C;\n", nil},
+		{"import a.B;\nimport static a.B.c;\n", []Finding{
+			{Line: 2, Message: "static import a.B.c must come before non-static imports"}}},
+		{"import b.C;\nimport a.B;\n", []Finding{
+			{Line: 2, Message: "import a.B must come before b.C"}}},
+	} {
+		if got := checkJavaImportOrder([]byte(tc.in)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("checkJavaImportOrder(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// prettierConfigNames are the prettier configuration files that are
+// read, in order of preference. As in prettier, the nearest one
+// applies.
+var prettierConfigNames = []string{".prettierrc", ".prettierrc.json", ".prettierrc.yaml", ".prettierrc.yml"}
+
+// prettierIgnoreName lists files that prettier leaves alone. Only
+// the one at the root is read, as prettier does when run from
+// there.
+const prettierIgnoreName = ".prettierignore"
+
+// prettierParsers maps file extensions to prettier parsers.
+var prettierParsers = map[string]string{
+	".ts":       "typescript",
+	".tsx":      "typescript",
+	".mts":      "typescript",
+	".cts":      "typescript",
+	".js":       "babel",
+	".jsx":      "babel",
+	".mjs":      "babel",
+	".cjs":      "babel",
+	".css":      "css",
+	".scss":     "scss",
+	".less":     "less",
+	".html":     "html",
+	".htm":      "html",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".yaml":     "yaml",
+	".yml":      "yaml",
+}
+
+// prettierFormatOptions are the prettier options that only change
+// the formatting. Other options, such as plugins, make prettier load
+// modules, so they are dropped from the configuration files, which
+// the change under review can modify, and rejected in
+// LanguageConfig.Args.
+var prettierFormatOptions = map[string]bool{
+	"arrowParens":                true,
+	"bracketSameLine":            true,
+	"bracketSpacing":             true,
+	"embeddedLanguageFormatting": true,
+	"endOfLine":                  true,
+	"experimentalTernaries":      true,
+	"htmlWhitespaceSensitivity":  true,
+	"jsxBracketSameLine":         true,
+	"jsxSingleQuote":             true,
+	"parser":                     true,
+	"printWidth":                 true,
+	"proseWrap":                  true,
+	"quoteProps":                 true,
+	"semi":                       true,
+	"singleAttributePerLine":     true,
+	"singleQuote":                true,
+	"tabWidth":                   true,
+	"trailingComma":              true,
+	"useTabs":                    true,
+	"vueIndentScriptAndStyle":    true,
+}
+
+// prettierOptionAllowed returns true if the option is in
+// prettierFormatOptions. A parser must be a built-in one, as other
+// values name modules.
+func prettierOptionAllowed(key string, val interface{}) bool {
+	if key != "parser" {
+		return prettierFormatOptions[key]
+	}
+	for _, p := range prettierParsers {
+		if val == p {
+			return true
+		}
+	}
+	return false
+}
+
+// prettierArgOption returns the option name and value of a command
+// line argument, eg. "printWidth" and "100" for "--print-width=100",
+// or "semi" and false for "--no-semi".
+func prettierArgOption(arg string) (string, interface{}) {
+	key := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+	var val interface{} = true
+	if i := strings.Index(key, "="); i >= 0 {
+		key, val = key[:i], key[i+1:]
+	} else if strings.HasPrefix(key, "no-") {
+		key, val = key[3:], false
+	}
+	parts := strings.Split(key, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, ""), val
+}
+
+// prettierRegex selects the files with a parser in prettierParsers.
+var prettierRegex = regexp.MustCompile(`\.(c?m?ts|tsx|[cm]?js|jsx|css|scss|less|html?|md|markdown|ya?ml)$`)
+
+// RegisterPrettier adds the prettier language, which formats with
+// the prettier package installed in dir, eg.
+// "/usr/lib/node_modules/prettier". It runs in a pool of Node
+// workers, with workers/node-worker.js and
+// workers/prettier-format.js next to the executable. It replaces
+// the clang-format javascript language, which would check the same
+// files in a different style.
+func RegisterPrettier(dir string) error {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	workers := filepath.Join(filepath.Dir(exe), "workers")
+	for _, f := range []string{"node-worker.js", "prettier-format.js"} {
+		if _, err := os.Stat(filepath.Join(workers, f)); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	supportFiles := append([]string{prettierIgnoreName}, prettierConfigNames...)
+	delete(formatters, "javascript")
+	formatters["prettier"] = &FormatterConfig{
+		Regex: prettierRegex,
+		Query: "(ext:ts OR ext:tsx OR ext:js OR ext:jsx OR ext:mjs OR ext:cjs OR ext:css OR ext:scss OR ext:less OR ext:html OR ext:md OR ext:yaml OR ext:yml)",
+		Formatter: &prettierFormatter{
+			command: []string{node,
+				filepath.Join(workers, "node-worker.js"),
+				filepath.Join(workers, "prettier-format.js"),
+				dir},
+		},
+		SupportFiles: supportFiles,
+	}
+	return nil
+}
+
+// prettierFormatter formats files with prettier, in a pool of Node
+// workers. The options for each file are resolved from the
+// .prettierrc files here, and passed to the worker as
+// "--options=<JSON>", followed by LanguageConfig.Args, eg.
+// "--print-width=100".
+type prettierFormatter struct {
+	command []string
+	args    []string
+}
+
+func (f *prettierFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	args := cfg.Language(lang).Args
+	if len(args) == 0 {
+		return f, nil
+	}
+	for _, a := range args {
+		if key, val := prettierArgOption(a); !prettierOptionAllowed(key, val) {
+			return nil, fmt.Errorf("prettier: option %q is not supported", a)
+		}
+	}
+	out := *f
+	out.args = append(append([]string{}, f.args...), args...)
+	return &out, nil
+}
+
+func (f *prettierFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	support := map[string][]byte{}
+	for _, file := range in {
+		if file.Support {
+			support[file.Name] = file.Content
+		}
+	}
+
+	// Files with the same options are formatted together.
+	groups := map[string][]File{}
+	var out []FormattedFile
+	for _, file := range in {
+		if file.Support {
+			continue
+		}
+		parser := prettierParsers[strings.ToLower(path.Ext(file.Name))]
+		if parser == "" || prettierIgnored(support[prettierIgnoreName], file.Name) {
+			out = append(out, FormattedFile{File: file})
+			continue
+		}
+		opts, err := prettierOptions(support, file.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := opts["parser"]; !ok {
+			opts["parser"] = parser
+		}
+		js, err := json.Marshal(opts)
+		if err != nil {
+			return nil, err
+		}
+		key := string(js)
+		groups[key] = append(groups[key], file)
+	}
+
+	var keys []string
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		wf := &workerFormatter{
+			command: f.command,
+			args:    append([]string{"--options=" + k}, f.args...),
+		}
+		formatted, err := wf.Format(groups[k], outSink)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, formatted...)
+	}
+	return out, nil
+}
+
+// prettierOptions returns the options of the nearest prettier
+// configuration file of name, with its overrides for name applied.
+// Options that are not prettierFormatOptions are dropped.
+func prettierOptions(support map[string][]byte, name string) (map[string]interface{}, error) {
+	opts := map[string]interface{}{}
+	var cfgName string
+	var content []byte
+	for d := path.Dir(name); cfgName == ""; d = path.Dir(d) {
+		if d == "." {
+			d = ""
+		}
+		for _, n := range prettierConfigNames {
+			p := path.Join(d, n)
+			if c, ok := support[p]; ok {
+				cfgName, content = p, c
+				break
+			}
+		}
+		if d == "" {
+			break
+		}
+	}
+	if cfgName == "" {
+		return opts, nil
+	}
+
+	var cfg struct {
+		Options   map[string]interface{} `yaml:",inline"`
+		Overrides []struct {
+			Files        interface{}            `yaml:"files"`
+			ExcludeFiles interface{}            `yaml:"excludeFiles"`
+			Options      map[string]interface{} `yaml:"options"`
+		} `yaml:"overrides"`
+	}
+	// YAML is a superset of JSON, so this reads both formats.
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", cfgName, err)
+	}
+	rel := name
+	if dir := path.Dir(cfgName); dir != "." {
+		rel = strings.TrimPrefix(name, dir+"/")
+	}
+	for k, v := range cfg.Options {
+		opts[k] = jsonValue(v)
+	}
+	for _, o := range cfg.Overrides {
+		if matchAnyGlob(stringList(o.Files), rel) && !matchAnyGlob(stringList(o.ExcludeFiles), rel) {
+			for k, v := range o.Options {
+				opts[k] = jsonValue(v)
+			}
+		}
+	}
+	for k, v := range opts {
+		if !prettierOptionAllowed(k, v) {
+			delete(opts, k)
+		}
+	}
+	return opts, nil
+}
+
+// stringList returns a YAML string or list of strings as a list.
+func stringList(v interface{}) []string {
+	switch x := v.(type) {
+	case string:
+		return []string{x}
+	case []interface{}:
+		var out []string
+		for _, e := range x {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// jsonValue converts the maps of a YAML value, which have interface
+// keys, so it can be marshaled to JSON.
+func jsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for k, e := range x {
+			out[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = jsonValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+// prettierIgnored returns true if the .prettierignore content, which
+// uses .gitignore syntax, matches the file or one of its
+// directories.
+func prettierIgnored(content []byte, name string) bool {
+	components := strings.Split(name, "/")
+	ignored := false
+	for _, l := range strings.Split(string(content), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(l, "!")
+		l = strings.TrimPrefix(l, "!")
+
+		dirOnly := strings.HasSuffix(l, "/")
+		l = strings.TrimSuffix(l, "/")
+		var pattern []string
+		if strings.Contains(l, "/") {
+			pattern = strings.Split(strings.TrimPrefix(l, "/"), "/")
+		} else {
+			pattern = []string{"**", l}
+		}
+
+		match := !dirOnly && matchComponents(pattern, components)
+		for i := 1; i < len(components) && !match; i++ {
+			match = matchComponents(pattern, components[:i])
+		}
+		if match {
+			ignored = !negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestPrettierOptions(t *testing.T) {
+	support := map[string][]byte{
+		".prettierrc": []byte(`{"semi": false, "overrides": [{"files": "*.md", "options": {"proseWrap": "always"}}]}`),
+		"web/.prettierrc.yaml": []byte(`
+printWidth: 100
+overrides:
+  - files: ["src/**/*.ts"]
+    excludeFiles: "src/gen/**"
+    options:
+      singleQuote: true
+`),
+	}
+	for name, want := range map[string]map[string]interface{}{
+		"a.ts":                {"semi": false},
+		"doc/a.md":            {"semi": false, "proseWrap": "always"},
+		"web/a.ts":            {"printWidth": 100},
+		"web/src/x/a.ts":      {"printWidth": 100, "singleQuote": true},
+		"web/src/gen/a.ts":    {"printWidth": 100},
+		"other/dir/a.ts":      {"semi": false},
+		"web/src/x/style.css": {"printWidth": 100},
+	} {
+		got, err := prettierOptions(support, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+
+	if got, err := prettierOptions(nil, "a.ts"); err != nil || len(got) != 0 {
+		t.Errorf("without config: got %v, %v", got, err)
+	}
+	if _, err := prettierOptions(map[string][]byte{".prettierrc": []byte("{")}, "a.ts"); err == nil {
+		t.Errorf("got no error for invalid config")
+	}
+
+	// Options that load modules are dropped.
+	got, err := prettierOptions(map[string][]byte{
+		".prettierrc": []byte(`{"plugins": ["./evil.js"], "pluginSearchDirs": ["."], "parser": "./evil.js", "semi": false}`),
+	}, "a.ts")
+	if want := map[string]interface{}{"semi": false}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("with plugins: got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestPrettierArgs(t *testing.T) {
+	for arg, ok := range map[string]bool{
+		"--print-width=100":        true,
+		"-print-width=100":         true,
+		"--no-semi":                true,
+		"--parser=typescript":      true,
+		"--parser=./evil.js":       false,
+		"--plugin=./evil.js":       false,
+		"-plugins=./evil.js":       false,
+		`--options={"plugins":[]}`: false,
+	} {
+		_, err := (&prettierFormatter{}).configure(&Config{Languages: map[string]*LanguageConfig{
+			"prettier": {Args: []string{arg}},
+		}}, "prettier")
+		if (err == nil) != ok {
+			t.Errorf("%s: got error %v, want ok %v", arg, err, ok)
+		}
+	}
+}
+
+func TestPrettierIgnored(t *testing.T) {
+	ignore := []byte(`
+# generated
+dist/
+*.min.js
+/vendor
+lib/**/*.gen.ts
+!lib/keep.gen.ts
+`)
+	for name, want := range map[string]bool{
+		"a.ts":                 false,
+		"dist/a.js":            true,
+		"web/dist/a.js":        true,
+		"web/app.min.js":       true,
+		"vendor/a.ts":          true,
+		"web/vendor/a.ts":      false,
+		"lib/x/y/a.gen.ts":     true,
+		"lib/keep.gen.ts":      false,
+		"lib/dist":             false,
+		"web/vendored/dist.ts": false,
+	} {
+		if got := prettierIgnored(ignore, name); got != want {
+			t.Errorf("prettierIgnored(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPrettierFormatter(t *testing.T) {
+	os.Setenv(testWorkerEnv, "1")
+	defer os.Unsetenv(testWorkerEnv)
+
+	f := &prettierFormatter{command: []string{os.Args[0]}}
+	cf, err := f.configure(&Config{Languages: map[string]*LanguageConfig{
+		"prettier": {Args: []string{"--print-width=100"}},
+	}}, "prettier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cf.Format([]File{
+		{Name: "a.ts", Content: []byte("a")},
+		{Name: "doc/b.md", Content: []byte("b")},
+		{Name: "dist/c.js", Content: []byte("c")},
+		{Name: "notes.txt", Content: []byte("d")},
+		{Name: ".prettierrc", Content: []byte("semi: false\n"), Support: true},
+		{Name: ".prettierignore", Content: []byte("dist/\n"), Support: true},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	got := map[string]string{}
+	for _, f := range out {
+		got[f.Name] = string(f.Content)
+	}
+	want := map[string]string{
+		"a.ts":      `A--options={"parser":"typescript","semi":false},--print-width=100`,
+		"doc/b.md":  `B--options={"parser":"markdown","semi":false},--print-width=100`,
+		"dist/c.js": "c",
+		"notes.txt": "d",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
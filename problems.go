@@ -0,0 +1,162 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// toolError is returned when a tool exits with an error.
+type toolError struct {
+	err            error
+	stderr, stdout string
+}
+
+// maxToolErrorLen bounds the tool output quoted in errors.
+const maxToolErrorLen = 200
+
+func (e *toolError) Error() string {
+	out := strings.TrimSpace(e.output())
+	if out == "" {
+		return e.err.Error()
+	}
+	if len(out) > maxToolErrorLen {
+		out = out[:maxToolErrorLen] + "..."
+	}
+	return fmt.Sprintf("%v: %s", e.err, out)
+}
+
+// output returns what the tool printed, stderr first.
+func (e *toolError) output() string {
+	return e.stderr + e.stdout
+}
+
+// defaultProblemMatchers match the usual "file:line:col: message"
+// format of compilers and formatters.
+var defaultProblemMatchers = []*regexp.Regexp{
+	regexp.MustCompile(`^(?P<file>[^:\s][^:]*):(?P<line>\d+):(?:(?P<col>\d+):)?\s*(?P<message>.+)$`),
+}
+
+// CompileProblemMatcher compiles a problem matcher: a regular
+// expression matching a line of tool output, with the named groups
+// "line" and optionally "file", "col", "message", "severity", "code"
+// and "url". If there is no "message" group, the whole line is the
+// message.
+func CompileProblemMatcher(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	hasLine := false
+	for _, n := range re.SubexpNames() {
+		switch n {
+		case "line":
+			hasLine = true
+		case "", "file", "col", "message", "severity", "code", "url":
+		default:
+			return nil, fmt.Errorf("problem matcher %q: unknown group %q", expr, n)
+		}
+	}
+	if !hasLine {
+		return nil, fmt.Errorf("problem matcher %q: needs a \"line\" group", expr)
+	}
+	return re, nil
+}
+
+// matchProblem matches a line of output, returning the named groups
+// or nil.
+func matchProblem(re *regexp.Regexp, line string) map[string]string {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	out := map[string]string{}
+	for i, n := range re.SubexpNames() {
+		if n != "" {
+			out[n] = m[i]
+		}
+	}
+	if _, ok := out["message"]; !ok {
+		out["message"] = line
+	}
+	return out
+}
+
+// problemFile returns true if the file named in a problem may be
+// name. Tools that read stdin name it "<stdin>" or "-".
+func problemFile(file, name string) bool {
+	switch file {
+	case "", "-", "<stdin>", name:
+		return true
+	}
+	return strings.HasSuffix(file, "/"+name) || path.Clean(file) == name
+}
+
+// parseProblems returns findings for the lines of tool output about
+// the named file that match one of the matchers, or
+// defaultProblemMatchers if there are none.
+func parseProblems(matchers []*regexp.Regexp, output, name string) []Finding {
+	if matchers == nil {
+		matchers = defaultProblemMatchers
+	}
+	var problems []map[string]string
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimRight(l, "\r")
+		for _, re := range matchers {
+			if m := matchProblem(re, l); m != nil && problemFile(m["file"], name) {
+				problems = append(problems, m)
+				break
+			}
+		}
+	}
+	return problemFindings(problems, name, nil)
+}
+
+// problemFindings converts problems, given as the named groups of a
+// problem matcher, into findings for the named file. Severities are
+// mapped with normalizeSeverity, and problems without one are
+// errors.
+func problemFindings(problems []map[string]string, name string, severities map[string]string) []Finding {
+	var out []Finding
+	for _, m := range problems {
+		if !problemFile(m["file"], name) {
+			continue
+		}
+		line, err := strconv.Atoi(m["line"])
+		if err != nil {
+			continue
+		}
+		msg := strings.TrimSpace(m["message"])
+		if code := m["code"]; code != "" {
+			msg = code + ": " + msg
+		}
+		if col := m["col"]; col != "" {
+			msg = fmt.Sprintf("column %s: %s", col, msg)
+		}
+		if url := m["url"]; url != "" {
+			msg = fmt.Sprintf("%s (%s)", msg, url)
+		}
+		f := Finding{Line: line, Message: msg}
+		if sev := m["severity"]; sev != "" {
+			f.Severity = normalizeSeverity(severities, sev)
+		}
+		out = append(out, f)
+	}
+	return out
+}
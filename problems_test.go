@@ -0,0 +1,133 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParseProblems(t *testing.T) {
+	custom := regexp.MustCompile(`^ERROR line (?P<line>\d+): (?P<message>.*)$`)
+	for _, tc := range []struct {
+		matchers []*regexp.Regexp
+		output   string
+		want     []Finding
+	}{
+		{nil, "a/b.c:3:7: expected ';'\n", []Finding{{Line: 3, Message: "column 7: expected ';'"}}},
+		{nil, "/tmp/x/a/b.c:3: oops", []Finding{{Line: 3, Message: "oops"}}},
+		{nil, "<stdin>:10: bad\n-:11:2: worse", []Finding{
+			{Line: 10, Message: "bad"},
+			{Line: 11, Message: "column 2: worse"},
+		}},
+		{nil, "other.c:3: not ours\nsomething failed", nil},
+		{[]*regexp.Regexp{custom}, "ERROR line 4: no good\na/b.c:3: ignored", []Finding{{Line: 4, Message: "no good"}}},
+	} {
+		if got := parseProblems(tc.matchers, tc.output, "a/b.c"); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseProblems(%q) = %v, want %v", tc.output, got, tc.want)
+		}
+	}
+}
+
+func TestCompileProblemMatcher(t *testing.T) {
+	for expr, ok := range map[string]bool{
+		`^(?P<line>\d+): (?P<message>.*)$`: true,
+		`^(?P<file>.*):(?P<line>\d+)`:      true,
+		`^(?P<message>.*)$`:                false,
+		`^(?P<row>\d+)(?P<line>\d+)`:       false,
+		`(`:                                false,
+	} {
+		if _, err := CompileProblemMatcher(expr); (err == nil) != ok {
+			t.Errorf("CompileProblemMatcher(%q): %v", expr, err)
+		}
+	}
+}
+
+func TestToolFormatterProblems(t *testing.T) {
+	// Upper-cases files, and fails on files containing "bad".
+	script := `for f; do
+  if grep -q bad "$f"; then echo "$f:2:5: unexpected bad" >&2; exit 1; fi
+  tr a-z A-Z < "$f" > "$f.tmp" && mv "$f.tmp" "$f"
+done`
+	f := &toolFormatter{bin: "sh", args: []string{"-c", script, "sh"}}
+	out, err := f.Format([]File{
+		{Name: "a.txt", Content: []byte("abc\n")},
+		{Name: "dir/b.txt", Content: []byte("ok\nbad\n")},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	want := []FormattedFile{
+		{File: File{Name: "a.txt", Content: []byte("ABC\n")}},
+		{
+			File:     File{Name: "dir/b.txt", Content: []byte("ok\nbad\n")},
+			Findings: []Finding{{Line: 2, Message: "column 5: unexpected bad"}},
+		},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("got %v, want %v", out, want)
+	}
+
+	f = &toolFormatter{bin: "sh", args: []string{"-c", "echo crashed >&2; exit 2", "sh"}}
+	if _, err := f.Format([]File{{Name: "a.txt", Content: []byte("abc\n")}}, ioutil.Discard); err == nil {
+		t.Errorf("got no error for crashing tool")
+	} else if want := "exit status 2: crashed"; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
+func TestRegisterTools(t *testing.T) {
+	defer delete(formatters, "upper")
+	if err := RegisterTools(map[string]*ToolConfig{
+		"upper": {
+			Command:         []string{"tr", "a-z", "A-Z"},
+			Globs:           []string{"*.up"},
+			ProblemMatchers: []string{`^line (?P<line>\d+)`},
+		},
+	}); err != nil {
+		t.Fatalf("RegisterTools: %v", err)
+	}
+	if !IsSupported("upper") {
+		t.Errorf("upper is not supported")
+	}
+
+	for name, tc := range map[string]*ToolConfig{
+		"go":    {Command: []string{"gofmt"}, Globs: []string{"*.go"}},
+		"empty": {Globs: []string{"*.x"}},
+		"badseverity": {
+			Command:    []string{"x"},
+			Globs:      []string{"*.x"},
+			Lint:       true,
+			Severities: map[string]string{"style": "fatal"},
+		},
+		"jsonformatter": {
+			Command: []string{"x"},
+			Globs:   []string{"*.x"},
+			JSON:    &JSONMapping{},
+		},
+		"noline": {
+			Command:         []string{"x"},
+			Globs:           []string{"*.x"},
+			ProblemMatchers: []string{`(?P<message>.*)`},
+		},
+	} {
+		if err := RegisterTools(map[string]*ToolConfig{name: tc}); err == nil {
+			t.Errorf("RegisterTools(%q) succeeded", name)
+			delete(formatters, name)
+		}
+	}
+}
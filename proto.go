@@ -0,0 +1,188 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ProtoConfig configures the proto-lint stage of the proto checker.
+type ProtoConfig struct {
+	// Disable lists rules that are not checked, eg.
+	// "ENUM_ZERO_VALUE_SUFFIX".
+	Disable []string `json:"disable,omitempty" yaml:"disable,omitempty"`
+}
+
+// The rules of protoLinter, named as in buf lint.
+const (
+	protoPackageRule  = "PACKAGE_LOWER_SNAKE_CASE"
+	protoFieldRule    = "FIELD_LOWER_SNAKE_CASE"
+	protoEnumZeroRule = "ENUM_ZERO_VALUE_SUFFIX"
+	protoNoReuseRule  = "FIELD_NO_REUSE"
+)
+
+var protoRules = []string{protoPackageRule, protoFieldRule, protoEnumZeroRule, protoNoReuseRule}
+
+var (
+	protoPackageRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*$`)
+	protoFieldRegex   = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+)
+
+// registerProto adds the proto language. It is formatted with
+// clang-format, given by path, if it is installed, and linted in
+// process.
+func registerProto(clang string) {
+	formatters["proto"] = &FormatterConfig{
+		Regex: regexp.MustCompile(`\.proto$`),
+		Query: "ext:proto",
+		Formatter: &pipelineFormatter{stages: []stage{
+			{"clang-format", &protoFormatter{clang: clang}},
+			{"proto-lint", &protoLinter{}},
+		}},
+		SupportFiles: clangFormatConfigNames,
+	}
+}
+
+// protoFormatter formats with clang-format, if installed.
+type protoFormatter struct {
+	clang string
+}
+
+func (f *protoFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	if f.clang == "" {
+		return nil, nil
+	}
+	return (&clangFormatter{bin: f.clang}).configure(cfg, lang)
+}
+
+func (f *protoFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	if f.clang == "" {
+		return unchanged(in), nil
+	}
+	return (&clangFormatter{bin: f.clang}).Format(in, outSink)
+}
+
+// unchanged returns the files, but for support files, as formatted.
+func unchanged(in []File) []FormattedFile {
+	var out []FormattedFile
+	for _, f := range in {
+		if !f.Support {
+			out = append(out, FormattedFile{File: f})
+		}
+	}
+	return out
+}
+
+// protoLinter checks the style of .proto files: lower_snake_case
+// package and field names, enum zero values named *_UNSPECIFIED, and
+// field numbers that are not reused within a message.
+type protoLinter struct {
+	disabled map[string]bool
+}
+
+func (l *protoLinter) configure(cfg *Config, lang string) (Formatter, error) {
+	out := &protoLinter{disabled: map[string]bool{}}
+	if cfg != nil && cfg.Proto != nil {
+		for _, r := range cfg.Proto.Disable {
+			known := false
+			for _, k := range protoRules {
+				known = known || k == r
+			}
+			if !known {
+				return nil, fmt.Errorf("proto: disable: unknown rule %q", r)
+			}
+			out.disabled[r] = true
+		}
+	}
+	return out, nil
+}
+
+func (l *protoLinter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	out := unchanged(in)
+	for i := range out {
+		pf, err := parseProto(out[i].Content)
+		if se, ok := err.(*protoSyntaxError); ok {
+			out[i].Findings = []Finding{{
+				Line:    se.line,
+				Message: fmt.Sprintf("syntax error at column %d: %s", se.col, se.msg),
+			}}
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", out[i].Name, err)
+		}
+		out[i].Findings = l.lint(pf)
+	}
+	return out, nil
+}
+
+// lint returns the findings for the file, sorted by line.
+func (l *protoLinter) lint(pf *protoFile) []Finding {
+	var out []Finding
+	report := func(rule string, line int, format string, args ...interface{}) {
+		if !l.disabled[rule] {
+			out = append(out, Finding{
+				Line:    line,
+				Message: rule + ": " + fmt.Sprintf(format, args...),
+			})
+		}
+	}
+
+	if pf.pkg != "" && !protoPackageRegex.MatchString(pf.pkg) {
+		report(protoPackageRule, pf.pkgLine, "package %q should be lower_snake_case", pf.pkg)
+	}
+
+	checkEnum := func(e *protoEnum) {
+		for _, v := range e.values {
+			if v.number == 0 && !strings.HasSuffix(v.name, "_UNSPECIFIED") {
+				report(protoEnumZeroRule, v.line, "enum zero value %q should end in _UNSPECIFIED", v.name)
+				break
+			}
+		}
+	}
+	var checkMessage func(m *protoMessage)
+	checkMessage = func(m *protoMessage) {
+		numbers := map[int]string{}
+		for _, f := range m.fields {
+			if !protoFieldRegex.MatchString(f.name) {
+				report(protoFieldRule, f.line, "field %q should be lower_snake_case", f.name)
+			}
+			if prev, ok := numbers[f.number]; ok {
+				report(protoNoReuseRule, f.line, "field %q reuses number %d of field %q", f.name, f.number, prev)
+			} else if m.reserved.number(f.number) {
+				report(protoNoReuseRule, f.line, "field %q uses reserved number %d", f.name, f.number)
+			}
+			numbers[f.number] = f.name
+		}
+		for _, e := range m.enums {
+			checkEnum(e)
+		}
+		for _, n := range m.messages {
+			checkMessage(n)
+		}
+	}
+	for _, e := range pf.enums {
+		checkEnum(e)
+	}
+	for _, m := range pf.messages {
+		checkMessage(m)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Line < out[j].Line })
+	return out
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testProto = `// A test file.
+syntax = "proto2";
+
+package Foo.bar;
+
+import public "other.proto";
+option java_package = "com.example.foo";
+option (custom) = { a: 1 b: "}" };
+
+/* Messages. */
+message Outer {
+  message Inner {
+    optional int32 x = 1 [default = -1, (opt).y = "a;b"];
+  }
+  enum Color {
+    option allow_alias = true;
+    RED = 0;
+    GREEN = 1 [deprecated = true];
+    reserved 2, 10 to max;
+    reserved "BLUE";
+  }
+  required string name = 1;
+  repeated .foo.Inner inner = 2;
+  map<string, Inner> byName = 3;
+  oneof choice {
+    int64 id = 4;
+    string key = 1;
+  }
+  optional group Result = 5 {
+    optional string url = 1;
+  }
+  reserved 6 to 8, 15;
+  reserved "old";
+  optional int32 reused = 7;
+  extensions 100 to 199;
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_OK = 0x1;
+}
+
+service Svc {
+  rpc Get(Outer) returns (Outer) { option idempotency_level = NO_SIDE_EFFECTS; }
+}
+`
+
+func TestParseProto(t *testing.T) {
+	pf, err := parseProto([]byte(testProto))
+	if err != nil {
+		t.Fatalf("parseProto: %v", err)
+	}
+	if pf.syntax != "proto2" || pf.pkg != "Foo.bar" || pf.pkgLine != 4 {
+		t.Errorf("got syntax %q, package %q on line %d", pf.syntax, pf.pkg, pf.pkgLine)
+	}
+	if len(pf.messages) != 1 || len(pf.enums) != 1 {
+		t.Fatalf("got %d messages, %d enums, want 1, 1", len(pf.messages), len(pf.enums))
+	}
+
+	m := pf.messages[0]
+	var fields []protoField
+	for _, f := range m.fields {
+		fields = append(fields, *f)
+	}
+	want := []protoField{
+		{name: "name", line: 22, label: "required", typ: "string", number: 1},
+		{name: "inner", line: 23, label: "repeated", typ: ".foo.Inner", number: 2},
+		{name: "byName", line: 24, typ: "map<string, Inner>", number: 3},
+		{name: "id", line: 26, typ: "int64", number: 4, oneof: "choice"},
+		{name: "key", line: 27, typ: "string", number: 1, oneof: "choice"},
+		{name: "result", line: 29, label: "optional", typ: "Result", number: 5},
+		{name: "reused", line: 34, label: "optional", typ: "int32", number: 7},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %+v, want %+v", fields, want)
+	}
+	if got := len(m.messages); got != 2 {
+		t.Errorf("got %d nested messages, want 2 (Inner, Result)", got)
+	}
+	wantReserved := protoReserved{ranges: [][2]int{{6, 8}, {15, 15}}, names: []string{"old"}}
+	if !reflect.DeepEqual(m.reserved, wantReserved) {
+		t.Errorf("got reserved %v, want %v", m.reserved, wantReserved)
+	}
+
+	color := m.enums[0]
+	if len(color.values) != 2 || color.values[1].name != "GREEN" || color.values[1].number != 1 {
+		t.Errorf("got enum values %v", color.values)
+	}
+	if !color.reserved.number(protoMaxEnum) || !color.reserved.name("BLUE") || color.reserved.number(3) {
+		t.Errorf("got enum reserved %v", color.reserved)
+	}
+	if v := pf.enums[0].values[1]; v.number != 1 {
+		t.Errorf("got %s = %d, want 1", v.name, v.number)
+	}
+}
+
+func TestParseProtoQualifiedType(t *testing.T) {
+	pf, err := parseProto([]byte("message A {\n  .pkg.Msg m = 1;\n  oneof o { .pkg.Msg n = 2; }\n}\n"))
+	if err != nil {
+		t.Fatalf("parseProto: %v", err)
+	}
+	var types []string
+	for _, f := range pf.messages[0].fields {
+		types = append(types, f.typ)
+	}
+	if want := []string{".pkg.Msg", ".pkg.Msg"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("got types %q, want %q", types, want)
+	}
+}
+
+func TestParseProtoErrors(t *testing.T) {
+	for in, want := range map[string]string{
+		"message A {\n  int32 x = ;\n}\n": "2:13: unexpected \";\"",
+		"message A {\n  int32 x = 1;\n":   "3:1: unexpected end of file",
+		"enum E { A = 0 }":                `1:16: expected ";", got "}"`,
+		"/* open":                         "1:1: unterminated comment",
+		"syntax = \"proto3;\n":            "1:10: unterminated string",
+		"messages A {}":                   `1:1: unexpected "messages"`,
+	} {
+		_, err := parseProto([]byte(in))
+		if err == nil || err.Error() != want {
+			t.Errorf("parseProto(%q): got %v, want %q", in, err, want)
+		}
+	}
+}
+
+func TestProtoLinter(t *testing.T) {
+	f, err := (&protoLinter{}).configure(nil, "proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Format([]File{
+		{Name: "a.proto", Content: []byte(testProto)},
+		{Name: "b.proto", Content: []byte("message A {\n  int32 x = ;\n}\n")},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	want := [][]Finding{
+		{
+			{Line: 4, Message: `PACKAGE_LOWER_SNAKE_CASE: package "Foo.bar" should be lower_snake_case`},
+			{Line: 17, Message: `ENUM_ZERO_VALUE_SUFFIX: enum zero value "RED" should end in _UNSPECIFIED`},
+			{Line: 24, Message: `FIELD_LOWER_SNAKE_CASE: field "byName" should be lower_snake_case`},
+			{Line: 27, Message: `FIELD_NO_REUSE: field "key" reuses number 1 of field "name"`},
+			{Line: 34, Message: `FIELD_NO_REUSE: field "reused" uses reserved number 7`},
+		},
+		{{Line: 2, Message: `syntax error at column 13: unexpected ";"`}},
+	}
+	var got [][]Finding
+	for _, o := range out {
+		got = append(got, o.Findings)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	cfg := &Config{Proto: &ProtoConfig{Disable: []string{protoPackageRule, protoFieldRule, protoEnumZeroRule, protoNoReuseRule}}}
+	if f, err = (&protoLinter{}).configure(cfg, "proto"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := f.Format([]File{{Name: "a.proto", Content: []byte(testProto)}}, ioutil.Discard); err != nil || len(out[0].Findings) != 0 {
+		t.Errorf("with all rules disabled: got %v, %v", out, err)
+	}
+	cfg.Proto.Disable = []string{"FIELD_NAMES"}
+	if _, err := (&protoLinter{}).configure(cfg, "proto"); err == nil || !strings.Contains(err.Error(), "unknown rule") {
+		t.Errorf("got %v, want unknown rule", err)
+	}
+}
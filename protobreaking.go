@@ -0,0 +1,240 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// The rules of protoBreakingLinter, named as in buf breaking where
+// it has them.
+const (
+	protoMessageDeleteRule   = "MESSAGE_NO_DELETE"
+	protoEnumDeleteRule      = "ENUM_NO_DELETE"
+	protoFieldDeleteRule     = "FIELD_NO_DELETE"
+	protoFieldNumberRule     = "FIELD_SAME_NUMBER"
+	protoFieldTypeRule       = "FIELD_SAME_TYPE"
+	protoFieldLabelRule      = "FIELD_SAME_LABEL"
+	protoEnumValueDeleteRule = "ENUM_VALUE_NO_DELETE"
+	protoEnumValueNumberRule = "ENUM_VALUE_SAME_NUMBER"
+)
+
+// protoBreakingLinter reports wire-incompatible changes of .proto
+// files against their content in the base revision (File.Base):
+// messages and enums that were removed or renamed, fields and enum
+// values that were removed without reserving their number, or
+// renumbered, and fields whose type or label changed.
+// Added files have nothing to break.
+type protoBreakingLinter struct{}
+
+func (l *protoBreakingLinter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	out := unchanged(in)
+	for i, f := range out {
+		if f.Base == nil {
+			continue
+		}
+		pf, err := parseProto(f.Content)
+		if se, ok := err.(*protoSyntaxError); ok {
+			out[i].Findings = []Finding{{
+				Line:    se.line,
+				Message: fmt.Sprintf("syntax error at column %d: %s", se.col, se.msg),
+			}}
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		base, err := parseProto(f.Base)
+		if err != nil {
+			// Nothing can be compared to an invalid base.
+			continue
+		}
+		out[i].Findings = protoBreakingChanges(base, pf)
+	}
+	return out, nil
+}
+
+// protoDecls holds the messages and enums of a file by full name.
+type protoDecls struct {
+	pkg      string
+	messages map[string]*protoMessage
+	enums    map[string]*protoEnum
+}
+
+func newProtoDecls(pf *protoFile) *protoDecls {
+	d := &protoDecls{
+		pkg:      pf.pkg,
+		messages: map[string]*protoMessage{},
+		enums:    map[string]*protoEnum{},
+	}
+	prefix := ""
+	if pf.pkg != "" {
+		prefix = pf.pkg + "."
+	}
+	var addMessage func(prefix string, m *protoMessage)
+	addMessage = func(prefix string, m *protoMessage) {
+		name := prefix + m.name
+		d.messages[name] = m
+		for _, e := range m.enums {
+			d.enums[name+"."+e.name] = e
+		}
+		for _, n := range m.messages {
+			addMessage(name+".", n)
+		}
+	}
+	for _, m := range pf.messages {
+		addMessage(prefix, m)
+	}
+	for _, e := range pf.enums {
+		d.enums[prefix+e.name] = e
+	}
+	return d
+}
+
+// sameType returns true if the type names a and b, as written in
+// files with packages pkgA and pkgB, may refer to the same type.
+// Names are not resolved, so "Inner" and "Outer.Inner" match.
+func sameType(a, pkgA, b, pkgB string) bool {
+	trim := func(t, pkg string) string {
+		t = strings.TrimPrefix(t, ".")
+		if pkg != "" {
+			t = strings.TrimPrefix(t, pkg+".")
+		}
+		return t
+	}
+	a, b = trim(a, pkgA), trim(b, pkgB)
+	return a == b || strings.HasSuffix(a, "."+b) || strings.HasSuffix(b, "."+a)
+}
+
+// removedLine returns the line of the new file at which to report
+// that the message or enum name was removed: that of its parent
+// message, or 0 for top-level declarations. It returns false if the
+// parent was removed too, which is reported instead.
+func removedLine(oldDecls, newDecls *protoDecls, name string) (int, bool) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return 0, true
+	}
+	parent := name[:i]
+	if _, ok := oldDecls.messages[parent]; !ok {
+		return 0, true
+	}
+	if m, ok := newDecls.messages[parent]; ok {
+		return m.line, true
+	}
+	return 0, false
+}
+
+// labelName describes a field label.
+func labelName(label string) string {
+	if label == "" {
+		return "singular"
+	}
+	return label
+}
+
+// protoBreakingChanges returns findings for the wire-incompatible
+// changes from base to pf, on the lines of pf, sorted by line.
+func protoBreakingChanges(base, pf *protoFile) []Finding {
+	var out []Finding
+	report := func(rule string, line int, format string, args ...interface{}) {
+		out = append(out, Finding{
+			Line:    line,
+			Message: rule + ": " + fmt.Sprintf(format, args...),
+		})
+	}
+
+	oldDecls, newDecls := newProtoDecls(base), newProtoDecls(pf)
+	var names []string
+	for n := range oldDecls.messages {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		oldMsg := oldDecls.messages[name]
+		newMsg, ok := newDecls.messages[name]
+		if !ok {
+			if line, ok := removedLine(oldDecls, newDecls, name); ok {
+				report(protoMessageDeleteRule, line, "message %s was removed or renamed", name)
+			}
+			continue
+		}
+		byNumber := map[int]*protoField{}
+		byName := map[string]*protoField{}
+		for _, f := range newMsg.fields {
+			byNumber[f.number] = f
+			byName[f.name] = f
+		}
+		for _, of := range oldMsg.fields {
+			nf, ok := byNumber[of.number]
+			if !ok {
+				if renamed, ok := byName[of.name]; ok {
+					report(protoFieldNumberRule, renamed.line, "field %q of %s was renumbered from %d to %d",
+						of.name, name, of.number, renamed.number)
+				} else if !newMsg.reserved.number(of.number) {
+					report(protoFieldDeleteRule, newMsg.line, "field %q (%d) of %s was removed without reserving its number",
+						of.name, of.number, name)
+				}
+				continue
+			}
+			if !sameType(of.typ, oldDecls.pkg, nf.typ, newDecls.pkg) {
+				report(protoFieldTypeRule, nf.line, "field %q of %s changed type from %s to %s",
+					nf.name, name, of.typ, nf.typ)
+			} else if of.label != nf.label {
+				report(protoFieldLabelRule, nf.line, "field %q of %s changed label from %s to %s",
+					nf.name, name, labelName(of.label), labelName(nf.label))
+			}
+		}
+	}
+
+	names = names[:0]
+	for n := range oldDecls.enums {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		oldEnum := oldDecls.enums[name]
+		newEnum, ok := newDecls.enums[name]
+		if !ok {
+			if line, ok := removedLine(oldDecls, newDecls, name); ok {
+				report(protoEnumDeleteRule, line, "enum %s was removed or renamed", name)
+			}
+			continue
+		}
+		byNumber := map[int]bool{}
+		byName := map[string]*protoEnumValue{}
+		for _, v := range newEnum.values {
+			byNumber[v.number] = true
+			byName[v.name] = v
+		}
+		for _, ov := range oldEnum.values {
+			if byNumber[ov.number] {
+				continue
+			}
+			if renamed, ok := byName[ov.name]; ok {
+				report(protoEnumValueNumberRule, renamed.line, "enum value %q of %s was renumbered from %d to %d",
+					ov.name, name, ov.number, renamed.number)
+			} else if !newEnum.reserved.number(ov.number) {
+				report(protoEnumValueDeleteRule, newEnum.line, "enum value %q (%d) of %s was removed without reserving its number",
+					ov.name, ov.number, name)
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Line < out[j].Line })
+	return out
+}
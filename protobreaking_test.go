@@ -0,0 +1,183 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+const baseProto = `syntax = "proto3";
+package foo;
+
+message Req {
+  string name = 1;
+  int32 count = 2;
+  repeated string tags = 3;
+  Inner inner = 4;
+  int64 old = 5;
+  bool gone = 6;
+  message Inner {
+    string id = 1;
+  }
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+    KIND_A = 1;
+    KIND_B = 2;
+    KIND_C = 3;
+  }
+}
+`
+
+const changedProto = `syntax = "proto3";
+package foo;
+
+message Req {
+  string name = 1;
+  int64 count = 2;
+  optional string tags = 3;
+  .foo.Req.Inner inner = 4;
+  int64 old = 7;
+  reserved 6;
+  string added = 8;
+  message Inner {
+    string renamed_id = 1;
+  }
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+    KIND_A = 5;
+    KIND_C = 3;
+    reserved "KIND_B";
+  }
+}
+`
+
+func TestProtoBreaking(t *testing.T) {
+	out, err := (&protoBreakingLinter{}).Format([]File{
+		{Name: "changed.proto", Content: []byte(changedProto), Base: []byte(baseProto)},
+		{Name: "added.proto", Content: []byte("message A { int32 x = 1; }")},
+		{Name: "same.proto", Content: []byte(baseProto), Base: []byte(baseProto)},
+		{Name: "invalid.proto", Content: []byte("message {"), Base: []byte(baseProto)},
+		{Name: "invalid_base.proto", Content: []byte(baseProto), Base: []byte("message {")},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	want := [][]Finding{
+		{
+			{Line: 6, Message: `FIELD_SAME_TYPE: field "count" of foo.Req changed type from int32 to int64`},
+			{Line: 7, Message: `FIELD_SAME_LABEL: field "tags" of foo.Req changed label from repeated to optional`},
+			{Line: 9, Message: `FIELD_SAME_NUMBER: field "old" of foo.Req was renumbered from 5 to 7`},
+			{Line: 15, Message: `ENUM_VALUE_NO_DELETE: enum value "KIND_B" (2) of foo.Req.Kind was removed without reserving its number`},
+			{Line: 17, Message: `ENUM_VALUE_SAME_NUMBER: enum value "KIND_A" of foo.Req.Kind was renumbered from 1 to 5`},
+		},
+		nil,
+		nil,
+		{{Line: 1, Message: `syntax error at column 9: unexpected "{"`}},
+		nil,
+	}
+	var got [][]Finding
+	for _, o := range out {
+		got = append(got, o.Findings)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	removed := `syntax = "proto3";
+package foo;
+
+message Req {
+  string name = 1;
+  message Inner {
+  }
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+  }
+}
+`
+	out, err = (&protoBreakingLinter{}).Format([]File{
+		{Name: "removed.proto", Content: []byte(removed), Base: []byte(baseProto)},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	want = [][]Finding{{
+		{Line: 4, Message: `FIELD_NO_DELETE: field "count" (2) of foo.Req was removed without reserving its number`},
+		{Line: 4, Message: `FIELD_NO_DELETE: field "tags" (3) of foo.Req was removed without reserving its number`},
+		{Line: 4, Message: `FIELD_NO_DELETE: field "inner" (4) of foo.Req was removed without reserving its number`},
+		{Line: 4, Message: `FIELD_NO_DELETE: field "old" (5) of foo.Req was removed without reserving its number`},
+		{Line: 4, Message: `FIELD_NO_DELETE: field "gone" (6) of foo.Req was removed without reserving its number`},
+		{Line: 6, Message: `FIELD_NO_DELETE: field "id" (1) of foo.Req.Inner was removed without reserving its number`},
+		{Line: 8, Message: `ENUM_VALUE_NO_DELETE: enum value "KIND_A" (1) of foo.Req.Kind was removed without reserving its number`},
+		{Line: 8, Message: `ENUM_VALUE_NO_DELETE: enum value "KIND_B" (2) of foo.Req.Kind was removed without reserving its number`},
+		{Line: 8, Message: `ENUM_VALUE_NO_DELETE: enum value "KIND_C" (3) of foo.Req.Kind was removed without reserving its number`},
+	}}
+	got = nil
+	for _, o := range out {
+		got = append(got, o.Findings)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	typesBase := `syntax = "proto3";
+package foo;
+
+message Keep {
+  message Nested {
+  }
+  enum Color {
+    COLOR_UNSPECIFIED = 0;
+  }
+}
+message Gone {
+  message Deeper {
+  }
+}
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+}
+`
+	typesChanged := `syntax = "proto3";
+package foo;
+
+message Keep {
+}
+enum State {
+  STATE_UNSPECIFIED = 0;
+}
+`
+	out, err = (&protoBreakingLinter{}).Format([]File{
+		{Name: "types.proto", Content: []byte(typesChanged), Base: []byte(typesBase)},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	want = [][]Finding{{
+		{Line: 0, Message: `MESSAGE_NO_DELETE: message foo.Gone was removed or renamed`},
+		{Line: 0, Message: `ENUM_NO_DELETE: enum foo.Status was removed or renamed`},
+		{Line: 4, Message: `MESSAGE_NO_DELETE: message foo.Keep.Nested was removed or renamed`},
+		{Line: 4, Message: `ENUM_NO_DELETE: enum foo.Keep.Color was removed or renamed`},
+	}}
+	got = nil
+	for _, o := range out {
+		got = append(got, o.Findings)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,611 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// protoFile is the part of a .proto file that the proto checkers
+// look at: packages, messages, fields and enums. Options, services
+// and extensions are skipped.
+type protoFile struct {
+	syntax   string
+	pkg      string
+	pkgLine  int
+	messages []*protoMessage
+	enums    []*protoEnum
+}
+
+type protoMessage struct {
+	name     string
+	line     int
+	fields   []*protoField
+	messages []*protoMessage
+	enums    []*protoEnum
+	reserved protoReserved
+}
+
+type protoField struct {
+	name string
+	line int
+
+	// label is "optional", "required", "repeated" or "".
+	label string
+
+	// typ is the type as written, eg. "int32", ".foo.Bar" or
+	// "map<string, Bar>".
+	typ    string
+	number int
+
+	// oneof is the name of the enclosing oneof, if any.
+	oneof string
+}
+
+type protoEnum struct {
+	name     string
+	line     int
+	values   []*protoEnumValue
+	reserved protoReserved
+}
+
+type protoEnumValue struct {
+	name   string
+	line   int
+	number int
+}
+
+// protoReserved holds the reserved numbers and names of a message
+// or enum.
+type protoReserved struct {
+	// ranges are inclusive.
+	ranges [][2]int
+	names  []string
+}
+
+// number returns true if n is reserved.
+func (r *protoReserved) number(n int) bool {
+	for _, rg := range r.ranges {
+		if rg[0] <= n && n <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns true if n is reserved.
+func (r *protoReserved) name(n string) bool {
+	for _, s := range r.names {
+		if s == n {
+			return true
+		}
+	}
+	return false
+}
+
+// The largest field and enum value numbers, which "max" stands for
+// in reserved ranges.
+const (
+	protoMaxField = 1<<29 - 1
+	protoMaxEnum  = 1<<31 - 1
+)
+
+// protoSyntaxError is returned by parseProto for invalid files.
+type protoSyntaxError struct {
+	line, col int
+	msg       string
+}
+
+func (e *protoSyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.line, e.col, e.msg)
+}
+
+// The kinds of protoToken.
+const (
+	protoEOF = iota
+	protoIdent
+	protoNumber
+	protoString
+	protoPunct
+)
+
+type protoToken struct {
+	kind      int
+	text      string
+	line, col int
+}
+
+// tokenizeProto splits content into tokens, dropping comments.
+func tokenizeProto(content []byte) ([]protoToken, error) {
+	s := string(content)
+	var out []protoToken
+	line, col := 1, 1
+	advance := func(n int) {
+		for _, c := range s[:n] {
+			if c == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+		s = s[n:]
+	}
+	isIdent := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	for len(s) > 0 {
+		c := s[0]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			advance(1)
+		case strings.HasPrefix(s, "//"):
+			n := strings.IndexByte(s, '\n')
+			if n < 0 {
+				n = len(s)
+			}
+			advance(n)
+		case strings.HasPrefix(s, "/*"):
+			n := strings.Index(s[2:], "*/")
+			if n < 0 {
+				return nil, &protoSyntaxError{line, col, "unterminated comment"}
+			}
+			advance(n + 4)
+		case c == '"' || c == '\'':
+			n := 1
+			for ; n < len(s) && s[n] != c && s[n] != '\n'; n++ {
+				if s[n] == '\\' {
+					n++
+				}
+			}
+			if n >= len(s) || s[n] != c {
+				return nil, &protoSyntaxError{line, col, "unterminated string"}
+			}
+			text, err := strconv.Unquote(`"` + strings.Replace(s[1:n], `"`, `\"`, -1) + `"`)
+			if err != nil {
+				text = s[1:n]
+			}
+			out = append(out, protoToken{protoString, text, line, col})
+			advance(n + 1)
+		case c >= '0' && c <= '9' || c == '.' && len(s) > 1 && s[1] >= '0' && s[1] <= '9':
+			n := 1
+			for n < len(s) && (isIdent(s[n]) || s[n] == '.' ||
+				(s[n] == '+' || s[n] == '-') && (s[n-1] == 'e' || s[n-1] == 'E')) {
+				n++
+			}
+			out = append(out, protoToken{protoNumber, s[:n], line, col})
+			advance(n)
+		case isIdent(c):
+			n := 1
+			for n < len(s) && (isIdent(s[n]) || s[n] == '.' && n+1 < len(s) && isIdent(s[n+1])) {
+				n++
+			}
+			out = append(out, protoToken{protoIdent, s[:n], line, col})
+			advance(n)
+		default:
+			out = append(out, protoToken{protoPunct, s[:1], line, col})
+			advance(1)
+		}
+	}
+	return append(out, protoToken{protoEOF, "", line, col}), nil
+}
+
+// protoParser parses the tokens of a .proto file.
+type protoParser struct {
+	toks []protoToken
+	pos  int
+}
+
+// parseProto parses a .proto file. Errors are *protoSyntaxError.
+func parseProto(content []byte) (*protoFile, error) {
+	toks, err := tokenizeProto(content)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{toks: toks}
+	return p.file()
+}
+
+func (p *protoParser) peek() protoToken {
+	return p.toks[p.pos]
+}
+
+func (p *protoParser) next() protoToken {
+	t := p.toks[p.pos]
+	if t.kind != protoEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *protoParser) errorf(t protoToken, format string, args ...interface{}) error {
+	return &protoSyntaxError{t.line, t.col, fmt.Sprintf(format, args...)}
+}
+
+// unexpected returns an error for the token t.
+func (p *protoParser) unexpected(t protoToken) error {
+	if t.kind == protoEOF {
+		return p.errorf(t, "unexpected end of file")
+	}
+	return p.errorf(t, "unexpected %q", t.text)
+}
+
+// accept consumes the next token if it is text.
+func (p *protoParser) accept(text string) bool {
+	if t := p.peek(); t.kind != protoString && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *protoParser) expect(text string) error {
+	if t := p.peek(); t.kind == protoString || t.text != text {
+		return p.errorf(t, "expected %q, got %q", text, t.text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *protoParser) ident() (protoToken, error) {
+	t := p.next()
+	if t.kind != protoIdent {
+		return t, p.unexpected(t)
+	}
+	return t, nil
+}
+
+// typeName parses a possibly qualified type name.
+func (p *protoParser) typeName() (string, error) {
+	prefix := ""
+	if p.accept(".") {
+		prefix = "."
+	}
+	t, err := p.ident()
+	return prefix + t.text, err
+}
+
+// skipStatement skips up to and including the next ";" outside of
+// braces.
+func (p *protoParser) skipStatement() error {
+	depth := 0
+	for {
+		t := p.next()
+		switch {
+		case t.kind == protoEOF:
+			return p.unexpected(t)
+		case t.kind != protoPunct:
+		case t.text == "{":
+			depth++
+		case t.text == "}":
+			depth--
+		case t.text == ";" && depth == 0:
+			return nil
+		}
+	}
+}
+
+// skipBlock skips a "{ ... }" block.
+func (p *protoParser) skipBlock() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for depth := 1; depth > 0; {
+		t := p.next()
+		switch {
+		case t.kind == protoEOF:
+			return p.unexpected(t)
+		case t.kind != protoPunct:
+		case t.text == "{":
+			depth++
+		case t.text == "}":
+			depth--
+		}
+	}
+	return nil
+}
+
+// skipOptions skips "[...]" field options, if present.
+func (p *protoParser) skipOptions() error {
+	if !p.accept("[") {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		t := p.next()
+		switch {
+		case t.kind == protoEOF:
+			return p.unexpected(t)
+		case t.kind != protoPunct:
+		case t.text == "[":
+			depth++
+		case t.text == "]":
+			depth--
+		}
+	}
+	return nil
+}
+
+// number parses an integer, which may be negative.
+func (p *protoParser) number() (int, error) {
+	neg := p.accept("-")
+	t := p.next()
+	if t.kind != protoNumber {
+		return 0, p.unexpected(t)
+	}
+	n, err := strconv.ParseInt(t.text, 0, 64)
+	if err != nil {
+		return 0, p.errorf(t, "invalid number %q", t.text)
+	}
+	if neg {
+		n = -n
+	}
+	return int(n), nil
+}
+
+func (p *protoParser) file() (*protoFile, error) {
+	f := &protoFile{}
+	for {
+		t := p.next()
+		var err error
+		switch {
+		case t.kind == protoEOF:
+			return f, nil
+		case t.text == ";":
+		case t.text == "syntax" || t.text == "edition":
+			if err = p.expect("="); err == nil {
+				f.syntax = p.next().text
+				err = p.expect(";")
+			}
+		case t.text == "package":
+			var name protoToken
+			if name, err = p.ident(); err == nil {
+				f.pkg, f.pkgLine = name.text, t.line
+				err = p.expect(";")
+			}
+		case t.text == "import" || t.text == "option":
+			err = p.skipStatement()
+		case t.text == "message":
+			var m *protoMessage
+			if m, err = p.message(t); err == nil {
+				f.messages = append(f.messages, m)
+			}
+		case t.text == "enum":
+			var e *protoEnum
+			if e, err = p.enum(t); err == nil {
+				f.enums = append(f.enums, e)
+			}
+		case t.text == "service" || t.text == "extend":
+			if _, err = p.typeName(); err == nil {
+				err = p.skipBlock()
+			}
+		default:
+			err = p.unexpected(t)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// message parses a message, after the "message" keyword t.
+func (p *protoParser) message(t protoToken) (*protoMessage, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m := &protoMessage{name: name.text, line: t.line}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	return m, p.messageBody(m, "")
+}
+
+// messageBody parses the statements of a message or oneof up to the
+// closing brace.
+func (p *protoParser) messageBody(m *protoMessage, oneof string) error {
+	for {
+		t := p.peek()
+		var err error
+		switch {
+		case t.kind == protoEOF:
+			return p.unexpected(t)
+		case p.accept("}"):
+			return nil
+		case p.accept(";"):
+		case t.kind != protoIdent && t.text != ".":
+			return p.unexpected(t)
+		case oneof == "" && t.text == "message":
+			p.next()
+			var n *protoMessage
+			if n, err = p.message(t); err == nil {
+				m.messages = append(m.messages, n)
+			}
+		case oneof == "" && t.text == "enum":
+			p.next()
+			var e *protoEnum
+			if e, err = p.enum(t); err == nil {
+				m.enums = append(m.enums, e)
+			}
+		case oneof == "" && t.text == "oneof":
+			p.next()
+			var name protoToken
+			if name, err = p.ident(); err == nil {
+				if err = p.expect("{"); err == nil {
+					err = p.messageBody(m, name.text)
+				}
+			}
+		case t.text == "option" || oneof == "" && t.text == "extensions":
+			err = p.skipStatement()
+		case oneof == "" && t.text == "extend":
+			p.next()
+			if _, err = p.typeName(); err == nil {
+				err = p.skipBlock()
+			}
+		case oneof == "" && t.text == "reserved":
+			p.next()
+			err = p.reserved(&m.reserved, protoMaxField)
+		default:
+			err = p.field(m, oneof)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// field parses a field, map field or group.
+func (p *protoParser) field(m *protoMessage, oneof string) error {
+	start := p.peek()
+	f := &protoField{line: start.line, oneof: oneof}
+	switch start.text {
+	case "optional", "required", "repeated":
+		f.label = p.next().text
+	}
+
+	if p.peek().text == "map" && p.toks[p.pos+1].text == "<" {
+		p.pos += 2
+		key, err := p.typeName()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		val, err := p.typeName()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(">"); err != nil {
+			return err
+		}
+		f.typ = "map<" + key + ", " + val + ">"
+	} else {
+		typ, err := p.typeName()
+		if err != nil {
+			return err
+		}
+		f.typ = typ
+	}
+
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	f.name = name.text
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	if f.number, err = p.number(); err != nil {
+		return err
+	}
+	if err := p.skipOptions(); err != nil {
+		return err
+	}
+
+	if f.typ == "group" {
+		// A proto2 group defines a message, and a field named
+		// after it in lower case.
+		g := &protoMessage{name: f.name, line: f.line}
+		f.typ, f.name = g.name, strings.ToLower(g.name)
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		if err := p.messageBody(g, ""); err != nil {
+			return err
+		}
+		m.messages = append(m.messages, g)
+	} else if err := p.expect(";"); err != nil {
+		return err
+	}
+	m.fields = append(m.fields, f)
+	return nil
+}
+
+// enum parses an enum, after the "enum" keyword t.
+func (p *protoParser) enum(t protoToken) (*protoEnum, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	e := &protoEnum{name: name.text, line: t.line}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for {
+		t := p.next()
+		var err error
+		switch {
+		case t.text == "}" && t.kind == protoPunct:
+			return e, nil
+		case t.text == ";" && t.kind == protoPunct:
+		case t.kind != protoIdent:
+			err = p.unexpected(t)
+		case t.text == "option":
+			err = p.skipStatement()
+		case t.text == "reserved":
+			err = p.reserved(&e.reserved, protoMaxEnum)
+		default:
+			v := &protoEnumValue{name: t.text, line: t.line}
+			if err = p.expect("="); err != nil {
+				break
+			}
+			if v.number, err = p.number(); err != nil {
+				break
+			}
+			if err = p.skipOptions(); err != nil {
+				break
+			}
+			if err = p.expect(";"); err == nil {
+				e.values = append(e.values, v)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// reserved parses the ranges or names of a reserved statement.
+func (p *protoParser) reserved(r *protoReserved, max int) error {
+	for {
+		t := p.peek()
+		switch t.kind {
+		case protoString, protoIdent:
+			p.next()
+			r.names = append(r.names, t.text)
+		default:
+			start, err := p.number()
+			if err != nil {
+				return err
+			}
+			end := start
+			if p.accept("to") {
+				if p.accept("max") {
+					end = max
+				} else if end, err = p.number(); err != nil {
+					return err
+				}
+			}
+			r.ranges = append(r.ranges, [2]int{start, end})
+		}
+		if p.accept(";") {
+			return nil
+		}
+		if err := p.expect(","); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// PythonConfig configures the python checker.
+type PythonConfig struct {
+	// Formatter is "ruff" (the default, if installed) for ruff
+	// format, or "black".
+	Formatter string `json:"formatter,omitempty" yaml:"formatter,omitempty"`
+
+	// Lint reports the diagnostics of ruff check.
+	Lint bool `json:"lint,omitempty" yaml:"lint,omitempty"`
+}
+
+// The values of PythonConfig.Formatter.
+const (
+	pythonRuff  = "ruff"
+	pythonBlack = "black"
+)
+
+// pythonConfigNames are the configuration files of ruff and black.
+// They are passed as support files, so the tools find the nearest
+// one, as they do locally.
+var pythonConfigNames = []string{"pyproject.toml", "ruff.toml", ".ruff.toml"}
+
+// registerPython adds the python language, if ruff or black is
+// installed, given by path.
+func registerPython(ruff, black string) {
+	if ruff == "" && black == "" {
+		return
+	}
+	formatters["python"] = &FormatterConfig{
+		Regex:  regexp.MustCompile(`\.pyi?$`),
+		Detect: []string{DetectedPython},
+		Query:  "(ext:py OR ext:pyi)",
+		Formatter: &pipelineFormatter{stages: []stage{
+			{"format", &pythonFormatter{ruff: ruff, black: black}},
+			{"ruff-check", &ruffLinter{ruff: ruff}},
+		}},
+		SupportFiles: pythonConfigNames,
+	}
+}
+
+// pythonFormatter formats with ruff format or black. Files excluded
+// by the ruff configuration are left alone, with --force-exclude.
+// Black has no such flag (its --force-exclude takes a regexp), but
+// applies the force-exclude setting of its configuration.
+type pythonFormatter struct {
+	ruff, black string
+}
+
+func (f *pythonFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	name := pythonRuff
+	if f.ruff == "" {
+		name = pythonBlack
+	}
+	if cfg != nil && cfg.Python != nil && cfg.Python.Formatter != "" {
+		name = cfg.Python.Formatter
+	}
+
+	var tf *toolFormatter
+	switch name {
+	case pythonRuff:
+		if f.ruff == "" {
+			return nil, fmt.Errorf("python: ruff is not installed")
+		}
+		tf = &toolFormatter{bin: f.ruff, args: []string{"format", "--no-cache", "--force-exclude"}}
+	case pythonBlack:
+		if f.black == "" {
+			return nil, fmt.Errorf("python: black is not installed")
+		}
+		tf = &toolFormatter{bin: f.black, args: []string{"--quiet"}}
+	default:
+		return nil, fmt.Errorf("python: formatter must be %q or %q, got %q", pythonRuff, pythonBlack, name)
+	}
+	return tf.configure(cfg, lang)
+}
+
+func (f *pythonFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	ff, err := f.configure(nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return ff.Format(in, outSink)
+}
+
+// ruffLinter reports the diagnostics of ruff check, if enabled with
+// PythonConfig.Lint.
+type ruffLinter struct {
+	ruff string
+}
+
+func (f *ruffLinter) configure(cfg *Config, lang string) (Formatter, error) {
+	if cfg == nil || cfg.Python == nil || !cfg.Python.Lint {
+		return nil, nil
+	}
+	if f.ruff == "" {
+		return nil, fmt.Errorf("python: lint needs ruff, which is not installed")
+	}
+	return f.linter(), nil
+}
+
+// linter returns the lintToolFormatter running ruff check.
+func (f *ruffLinter) linter() *lintToolFormatter {
+	return &lintToolFormatter{
+		bin:  f.ruff,
+		args: []string{"check", "--output-format", "json", "--no-cache", "--no-fix", "--force-exclude"},
+		json: &JSONMapping{
+			File:   "filename",
+			Line:   "location.row",
+			Column: "location.column",
+			Code:   "code",
+		},
+	}
+}
+
+func (f *ruffLinter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	return f.linter().Format(in, outSink)
+}
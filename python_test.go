@@ -0,0 +1,189 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRuff writes a script that formats by upper-casing, unless the
+// file is excluded in pyproject.toml, and reports an unused import
+// for each "import" line.
+func fakeRuff(t *testing.T, dir string) string {
+	bin := filepath.Join(dir, "ruff")
+	script := `#!/bin/sh
+cmd=$1
+shift
+for a; do
+  case "$a" in
+    -*|json) continue ;;
+  esac
+  if grep -q "exclude.*$a" pyproject.toml 2>/dev/null; then
+    [ "$cmd" = check ] && echo '[]'
+    continue
+  fi
+  if [ "$cmd" = format ]; then
+    tr a-z A-Z < "$a" > "$a.tmp" && mv "$a.tmp" "$a"
+  else
+    n=$(grep -in '^import' "$a" | cut -d: -f1 | head -1)
+    if [ -z "$n" ]; then echo '[]'; exit 0; fi
+    echo "[{\"filename\": \"$PWD/$a\", \"location\": {\"row\": $n, \"column\": 8}, \"code\": \"F401\", \"message\": \"unused import\"}]"
+    exit 1
+  fi
+done
+`
+	if err := ioutil.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+// fakeBlack writes a script that formats by upper-casing, unless the
+// file matches the force-exclude regexp of pyproject.toml. Like
+// black, it takes a regexp after --force-exclude.
+func fakeBlack(t *testing.T, dir string) string {
+	bin := filepath.Join(dir, "black")
+	script := `#!/bin/sh
+exclude=$(sed -n 's/^force-exclude = "\(.*\)"$/\1/p' pyproject.toml 2>/dev/null)
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --force-exclude) exclude=$2; shift 2; continue ;;
+    -*) shift; continue ;;
+  esac
+  if [ -z "$exclude" ] || ! echo "$1" | grep -q "$exclude"; then
+    tr a-z A-Z < "$1" > "$1.tmp" && mv "$1.tmp" "$1"
+  fi
+  shift
+done
+`
+	if err := ioutil.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestBlack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := (&pythonFormatter{black: fakeBlack(t, dir)}).configure(nil, "python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Format([]File{
+		{Name: "a.py", Content: []byte("x = 1\n")},
+		{Name: "gen/b.py", Content: []byte("x = 1\n")},
+		{Name: "pyproject.toml", Content: []byte("[tool.black]\nforce-exclude = \"gen/\"\n"), Support: true},
+	}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	got := map[string]string{}
+	for _, o := range out {
+		got[o.Name] = string(o.Content)
+	}
+	want := map[string]string{"a.py": "X = 1\n", "gen/b.py": "x = 1\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPythonPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ruff := fakeRuff(t, dir)
+
+	p := &pipelineFormatter{stages: []stage{
+		{"format", &pythonFormatter{ruff: ruff}},
+		{"ruff-check", &ruffLinter{ruff: ruff}},
+	}}
+	in := []File{
+		{Name: "a.py", Content: []byte("import os\n")},
+		{Name: "gen/b.py", Content: []byte("import os\n")},
+		{Name: "pyproject.toml", Content: []byte("[tool.ruff]\nexclude = [\"gen/b.py\"]\n"), Support: true},
+	}
+	for _, tc := range []struct {
+		cfg  *Config
+		want [][]Finding
+	}{
+		{nil, [][]Finding{{{Line: 1, Message: "found a difference", Stage: "format"}}, nil}},
+		{&Config{Python: &PythonConfig{Lint: true}}, [][]Finding{
+			{
+				{Line: 1, Message: "found a difference", Stage: "format"},
+				{Line: 1, Message: "column 8: F401: unused import", Stage: "ruff-check"},
+			},
+			nil,
+		}},
+	} {
+		f, err := p.configure(tc.cfg, "python")
+		if err != nil {
+			t.Fatalf("configure: %v", err)
+		}
+		out, err := f.Format(in, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Format: %v", err)
+		}
+		var got [][]Finding
+		for _, o := range out {
+			got = append(got, o.Findings)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("config %v: got %v, want %v", tc.cfg, got, tc.want)
+		}
+	}
+}
+
+func TestPythonConfigure(t *testing.T) {
+	for _, tc := range []struct {
+		ruff, black string
+		cfg         *PythonConfig
+		bin, err    string
+	}{
+		{"ruff", "black", nil, "ruff", ""},
+		{"", "black", nil, "black", ""},
+		{"ruff", "black", &PythonConfig{Formatter: "black"}, "black", ""},
+		{"ruff", "", &PythonConfig{Formatter: "black"}, "", "not installed"},
+		{"ruff", "black", &PythonConfig{Formatter: "yapf"}, "", "must be"},
+	} {
+		f := &pythonFormatter{ruff: tc.ruff, black: tc.black}
+		got, err := f.configure(&Config{Python: tc.cfg}, "python")
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%v: got error %v, want %q", tc.cfg, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tc.cfg, err)
+		} else if bin := got.(*toolFormatter).bin; bin != tc.bin {
+			t.Errorf("%v: got %q, want %q", tc.cfg, bin, tc.bin)
+		}
+	}
+
+	if _, err := (&ruffLinter{}).configure(&Config{Python: &PythonConfig{Lint: true}}, "python"); err == nil {
+		t.Errorf("lint without ruff: got no error")
+	}
+}
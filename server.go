@@ -22,6 +22,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -40,11 +41,43 @@ type FormatterConfig struct {
 	// Regex is the typical filename regexp to use
 	Regex *regexp.Regexp
 
+	// Globs are filename globs (see MatchGlob) to use in addition
+	// to Regex.
+	Globs []string
+
+	// Detect lists the languages returned by DetectLanguage that
+	// the formatter handles, to select files that Regex and Globs
+	// miss, such as scripts without an extension.
+	Detect []string
+
+	// Shebang selects files whose shebang line names one of the
+	// Detect languages, even without LanguageConfig.DetectContent.
+	Shebang bool
+
 	// Query is used to filter inside Gerrit
 	Query string
 
 	// The formatter
 	Formatter Formatter
+
+	// CommitHeader requests that the author, committer and
+	// uploader of the patch set are prepended to /COMMIT_MSG, in
+	// the format of CommitHeader.
+	CommitHeader bool
+
+	// Symlinks includes symbolic links, with their target as
+	// content.
+	Symlinks bool
+
+	// BaseContent requests the content of modified files in the
+	// base revision, in File.Base.
+	BaseContent bool
+
+	// SupportFiles are base names of configuration files, eg.
+	// ".editorconfig". They are looked up in the directories of
+	// the checked files and their parents, and passed to the
+	// formatter with File.Support set.
+	SupportFiles []string
 }
 
 // formatters holds all the formatters supported
@@ -53,6 +86,51 @@ var formatters = map[string]*FormatterConfig{
 		Regex:     regexp.MustCompile(`^/COMMIT_MSG$`),
 		Formatter: &commitMsgFormatter{},
 	},
+	"identity": {
+		Regex:        regexp.MustCompile(`^/COMMIT_MSG$`),
+		Formatter:    &identityFormatter{},
+		CommitHeader: true,
+	},
+	"whitespace": {
+		Globs:     whitespaceGlobs,
+		Detect:    []string{DetectedShell},
+		Formatter: &whitespaceFormatter{},
+	},
+	"editorconfig": {
+		Regex:        regexp.MustCompile(`^[^/]`),
+		Formatter:    &editorConfigFormatter{},
+		SupportFiles: []string{editorConfigName},
+	},
+	"filemode": {
+		Regex:     regexp.MustCompile(`^[^/]`),
+		Formatter: &fileModeFormatter{},
+		Symlinks:  true,
+	},
+	"bzl": {
+		Regex:  regexp.MustCompile(`(\.bzl|(^|/)(BUILD|WORKSPACE)(\.bazel)?)$`),
+		Detect: []string{DetectedStarlark},
+		Query:  "(ext:bzl OR file:BUILD OR file:WORKSPACE OR file:BUILD.bazel OR file:WORKSPACE.bazel)",
+		Formatter: &pipelineFormatter{stages: []stage{
+			{"buildifier", &buildifierFormatter{}},
+			{"buildifier-lint", &buildifierLinter{}},
+		}},
+	},
+	"proto-breaking": {
+		Regex:       regexp.MustCompile(`\.proto$`),
+		Query:       "ext:proto",
+		Formatter:   &protoBreakingLinter{},
+		BaseContent: true,
+	},
+	"go": {
+		Regex:  regexp.MustCompile(`\.go$`),
+		Detect: []string{DetectedGo},
+		Query:  "ext:go",
+		Formatter: &pipelineFormatter{stages: []stage{
+			{"gofmt", &goFormatter{simplify: true}},
+			{"goimports", &goImportsFormatter{}},
+			{"line-length", &lineLengthLinter{}},
+		}},
+	},
 }
 
 func init() {
@@ -64,44 +142,115 @@ func init() {
 	gjf, err := exec.LookPath("google-java-format.jar")
 	if err == nil {
 		formatters["java"] = &FormatterConfig{
-			Regex: regexp.MustCompile(`\.java$`),
-			Query: "ext:java",
-			Formatter: &toolFormatter{
-				bin:  "java",
-				args: []string{"-jar", gjf, "-i"},
-			},
+			Regex:  regexp.MustCompile(`\.java$`),
+			Detect: []string{DetectedJava},
+			Query:  "ext:java",
+			Formatter: &pipelineFormatter{stages: []stage{
+				{"google-java-format", gjfFormatter(gjf)},
+				{"import-order", &javaImportOrderLinter{}},
+			}},
 		}
 	} else {
 		log.Printf("LookPath google-java-format: %v PATH=%s", err, os.Getenv("PATH"))
 	}
 
-	bzl, err := exec.LookPath("buildifier")
+	shfmt, _ := exec.LookPath("shfmt")
+	shellcheck, _ := exec.LookPath("shellcheck")
+	registerShell(shfmt, shellcheck)
+
+	ruff, _ := exec.LookPath("ruff")
+	black, _ := exec.LookPath("black")
+	registerPython(ruff, black)
+
+	clang, err := exec.LookPath("clang-format")
 	if err == nil {
-		formatters["bzl"] = &FormatterConfig{
-			Regex: regexp.MustCompile(`(\.bzl|/BUILD|^BUILD)$`),
-			Query: "(ext:bzl OR file:BUILD OR file:WORKSPACE)",
-			Formatter: &toolFormatter{
-				bin:  bzl,
-				args: []string{"-mode=fix"},
-			},
-		}
+		registerClangFormat(clang)
 	} else {
-		log.Printf("LookPath buildifier: %v, PATH=%s", err, os.Getenv("PATH"))
+		log.Printf("LookPath clang-format: %v", err)
 	}
+	registerProto(clang)
+}
 
-	gofmt, err := exec.LookPath("gofmt")
-	if err == nil {
-		formatters["go"] = &FormatterConfig{
-			Regex: regexp.MustCompile(`\.go$`),
-			Query: "ext:go",
-			Formatter: &toolFormatter{
-				bin:  gofmt,
-				args: []string{"-w"},
-			},
+// gjfFormatter returns the formatter for the google-java-format
+// jar. It uses a pool of workers if workers/GjfWorker.java is
+// installed next to the jar, to avoid starting a JVM for each
+// check.
+func gjfFormatter(jar string) Formatter {
+	worker := filepath.Join(filepath.Dir(jar), "workers", "GjfWorker.java")
+	if _, err := os.Stat(worker); err == nil {
+		return &workerFormatter{
+			command: []string{"java", "-cp", jar, worker},
+			lines:   "--lines=%d:%d",
 		}
-	} else {
-		log.Printf("LookPath gofmt: %v, PATH=%s", err, os.Getenv("PATH"))
 	}
+	return &toolFormatter{
+		bin:   "java",
+		args:  []string{"-jar", jar, "-i"},
+		lines: "--lines=%d:%d",
+	}
+}
+
+// Excluded returns true if the file would be checked, but for the
+// Exclude globs of the language settings.
+func (fc *FormatterConfig) Excluded(name string, lc *LanguageConfig) bool {
+	return matchAnyGlob(lc.Exclude, name) && fc.Matches(name, &LanguageConfig{Include: lc.Include})
+}
+
+// Matches returns true if the file should be checked. The Include
+// globs of the language settings take precedence over Regex and
+// Globs, and the Exclude globs over everything.
+func (fc *FormatterConfig) Matches(name string, lc *LanguageConfig) bool {
+	if matchAnyGlob(lc.Exclude, name) {
+		return false
+	}
+	if len(lc.Include) > 0 {
+		return matchAnyGlob(lc.Include, name)
+	}
+	if fc.Regex != nil && fc.Regex.MatchString(name) {
+		return true
+	}
+	return matchAnyGlob(fc.Globs, name)
+}
+
+// MatchesContent is like Matches, but also selects files that
+// DetectByName, their shebang line if fc.Shebang is set, or if
+// lc.DetectContent is set, DetectLanguage recognizes as one of the
+// Detect languages. Files selected by Include are not detected.
+func (fc *FormatterConfig) MatchesContent(name string, content []byte, lc *LanguageConfig) bool {
+	if fc.Matches(name, lc) {
+		return true
+	}
+	if len(fc.Detect) == 0 || len(lc.Include) > 0 || strings.HasPrefix(name, "/") || matchAnyGlob(lc.Exclude, name) {
+		return false
+	}
+	lang := DetectByName(name)
+	if lang == "" && fc.Shebang {
+		lang = detectShebang(content)
+	}
+	if lang == "" && lc.DetectContent {
+		lang = DetectLanguage(name, content)
+	}
+	for _, d := range fc.Detect {
+		if lang != "" && d == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// optionalLanguages are the languages that are only registered if
+// their tools are installed on the host. Repository settings for
+// them are valid everywhere, and have no effect where they are
+// missing.
+var optionalLanguages = map[string]bool{
+	"c":          true,
+	"cpp":        true,
+	"java":       true,
+	"javascript": true,
+	"objc":       true,
+	"prettier":   true,
+	"python":     true,
+	"shell":      true,
 }
 
 func GetFormatter(lang string) (*FormatterConfig, bool) {
@@ -119,6 +268,31 @@ func GetFormatter(lang string) (*FormatterConfig, bool) {
 	return cfg, ok
 }
 
+// SupportPaths returns the paths where support files with the given
+// base names may be found for the given files, ordered from the
+// root down.
+func SupportPaths(baseNames []string, files []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, f := range files {
+		var dirs []string
+		for d := path.Dir(f); d != "." && d != "/"; d = path.Dir(d) {
+			dirs = append(dirs, d)
+		}
+		dirs = append(dirs, "")
+		for i := len(dirs) - 1; i >= 0; i-- {
+			for _, b := range baseNames {
+				p := path.Join(dirs[i], b)
+				if !seen[p] {
+					seen[p] = true
+					out = append(out, p)
+				}
+			}
+		}
+	}
+	return out
+}
+
 // IsSupported returns if the given language is supported.
 func IsSupported(lang string) bool {
 	_, ok := GetFormatter(lang)
@@ -157,7 +331,11 @@ func Format(req *FormatRequest, rep *FormatReply) error {
 		if !ok {
 			return fmt.Errorf("linter: no formatter for %q", language)
 		}
-		out, err := entry.Formatter.Format(fs, &buf)
+		formatter, err := entry.configure(req.Config, language)
+		if err != nil {
+			return err
+		}
+		out, err := formatter.Format(fs, &buf)
 		if err != nil {
 			return err
 		}
@@ -170,10 +348,27 @@ func Format(req *FormatRequest, rep *FormatReply) error {
 	return nil
 }
 
-type commitMsgFormatter struct{}
+type commitMsgFormatter struct {
+	policy CommitMessagePolicy
+}
+
+func (f *commitMsgFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	out := &commitMsgFormatter{}
+	if cfg != nil && cfg.CommitMessage != nil {
+		out.policy = *cfg.CommitMessage
+	}
+	return out, nil
+}
 
 func (f *commitMsgFormatter) Format(in []File, outSink io.Writer) (out []FormattedFile, err error) {
-	complaint := checkCommitMessage(string(in[0].Content))
+	msg := string(in[0].Content)
+	complaint := checkCommitMessage(msg, &f.policy)
+	for _, footer := range f.policy.RequiredFooters {
+		if complaint != "" {
+			break
+		}
+		complaint = checkCommitFooter(msg, footer)
+	}
 	ff := FormattedFile{}
 	ff.Name = in[0].Name
 	if complaint != "" {
@@ -185,7 +380,11 @@ func (f *commitMsgFormatter) Format(in []File, outSink io.Writer) (out []Formatt
 	return out, nil
 }
 
-func checkCommitMessage(msg string) (complaint string) {
+// defaultMaxSubjectLength is the subject length limit if the
+// CommitMessagePolicy doesn't set one.
+const defaultMaxSubjectLength = 70
+
+func checkCommitMessage(msg string, policy *CommitMessagePolicy) (complaint string) {
 	lines := strings.Split(msg, "\n")
 	if len(lines) < 2 {
 		return "must have multiple lines"
@@ -195,17 +394,144 @@ func checkCommitMessage(msg string) (complaint string) {
 		return "subject and body must be separated by blank line"
 	}
 
-	if len(lines[0]) > 70 {
-		return "subject must be less than 70 chars"
+	if revertRegex.MatchString(lines[0]) {
+		return checkRevert(msg)
+	}
+
+	maxLen := policy.MaxSubjectLength
+	if maxLen == 0 {
+		maxLen = defaultMaxSubjectLength
+	}
+	if len(lines[0]) > maxLen {
+		return fmt.Sprintf("subject must be less than %d chars", maxLen)
 	}
 
 	if strings.HasSuffix(lines[0], ".") {
 		return "subject must not end in '.'"
 	}
 
+	return checkCherryPick(msg)
+}
+
+var (
+	// revertRegex matches the subject of reverts created by Gerrit,
+	// which is `Revert "<original subject>"`, or `Revert^N "..."`
+	// for reverts of reverts.
+	revertRegex = regexp.MustCompile(`^Revert(\^[0-9]+)? "(.*)"$`)
+
+	revertedRegex   = regexp.MustCompile(`^This reverts commit ([^ ]*)\.$`)
+	cherryPickRegex = regexp.MustCompile(`^\(cherry picked from commit ([^ ]*)\)$`)
+	shaRegex        = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// revertPlaceholder is the text that Gerrit suggests for the reason
+// of a revert.
+const revertPlaceholder = "<INSERT REASONING HERE>"
+
+// checkRevert checks a commit message whose subject has the revert
+// form. Reverts are exempt from the subject length limit, as they
+// quote the original subject.
+func checkRevert(msg string) string {
+	paragraphs := splitParagraphs(msg)
+	if len(paragraphs) < 2 {
+		return "revert must have a body"
+	}
+
+	m := revertRegex.FindStringSubmatch(strings.SplitN(paragraphs[0], "\n", 2)[0])
+	if m == nil || m[2] == "" {
+		return "revert subject must quote the original subject"
+	}
+
+	// The last paragraph holds the footers, if there are any.
+	body := paragraphs[1:]
+	if len(body) > 1 && isFooterBlock(body[len(body)-1]) {
+		body = body[:len(body)-1]
+	}
+
+	found := false
+	hasReason := false
+	for _, p := range body {
+		for _, l := range strings.Split(p, "\n") {
+			if m := revertedRegex.FindStringSubmatch(l); m != nil {
+				if !shaRegex.MatchString(m[1]) {
+					return fmt.Sprintf("reverted commit %q must be a 40 character hex SHA-1", m[1])
+				}
+				found = true
+				continue
+			}
+			if strings.Contains(l, revertPlaceholder) {
+				return "revert reason must replace " + revertPlaceholder
+			}
+			if cherryPickRegex.MatchString(l) {
+				continue
+			}
+			if strings.TrimSpace(strings.TrimPrefix(l, "Reason for revert:")) != "" {
+				hasReason = true
+			}
+		}
+	}
+
+	if !found {
+		return "revert must say 'This reverts commit <sha>.'"
+	}
+	if !hasReason {
+		return "revert must have a paragraph explaining the reason"
+	}
+	return checkCherryPick(msg)
+}
+
+// splitParagraphs splits a commit message into paragraphs. Lines
+// holding only whitespace separate paragraphs, like empty lines do.
+func splitParagraphs(msg string) []string {
+	var paragraphs []string
+	var cur []string
+	for _, l := range strings.Split(msg, "\n") {
+		if strings.TrimSpace(l) == "" {
+			if len(cur) > 0 {
+				paragraphs = append(paragraphs, strings.Join(cur, "\n"))
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, l)
+	}
+	if len(cur) > 0 {
+		paragraphs = append(paragraphs, strings.Join(cur, "\n"))
+	}
+	return paragraphs
+}
+
+// checkCherryPick checks the "(cherry picked from commit <sha>)"
+// lines of a commit message.
+func checkCherryPick(msg string) string {
+	for _, l := range strings.Split(msg, "\n") {
+		if !strings.HasPrefix(l, "(cherry picked from") {
+			continue
+		}
+		m := cherryPickRegex.FindStringSubmatch(l)
+		if m == nil {
+			return "cherry-pick line must say '(cherry picked from commit <sha>)'"
+		}
+		if !shaRegex.MatchString(m[1]) {
+			return fmt.Sprintf("cherry-picked commit %q must be a 40 character hex SHA-1", m[1])
+		}
+	}
 	return ""
 }
 
+var footerRegex = regexp.MustCompile(`^[A-Za-z0-9-]+: `)
+
+// isFooterBlock returns true if all lines of the paragraph are
+// footers.
+func isFooterBlock(p string) bool {
+	for _, l := range strings.Split(p, "\n") {
+		if !footerRegex.MatchString(l) {
+			return false
+		}
+	}
+	return true
+}
+
 type commitFooterFormatter struct {
 	Footer string
 }
@@ -260,17 +586,26 @@ func checkCommitFooter(message, footer string) string {
 type toolFormatter struct {
 	bin  string
 	args []string
+
+	// lines is a format string with the start and end (inclusive)
+	// of a line range, that restricts formatting to those lines,
+	// eg. "--lines=%d:%d". If empty, File.Lines is ignored.
+	lines string
+
+	// matchers parse the problems that the tool prints when it
+	// fails, eg. syntax errors. If nil, defaultProblemMatchers
+	// are used.
+	matchers []*regexp.Regexp
 }
 
 func (f *toolFormatter) Format(in []File, outSink io.Writer) (out []FormattedFile, err error) {
-	cmd := exec.Command(f.bin, f.args...)
-
 	tmpDir, err := ioutil.TempDir("", "gerritfmt")
 	if err != nil {
 		return nil, err
 	}
 	defer os.RemoveAll(tmpDir)
 
+	var all, restricted []File
 	for _, f := range in {
 		dir, base := filepath.Split(f.Name)
 		dir = filepath.Join(tmpDir, dir)
@@ -282,21 +617,64 @@ func (f *toolFormatter) Format(in []File, outSink io.Writer) (out []FormattedFil
 			return nil, err
 		}
 
-		cmd.Args = append(cmd.Args, f.Name)
+		if f.Support {
+			continue
+		} else if f.Lines != nil {
+			restricted = append(restricted, f)
+		} else {
+			all = append(all, f)
+		}
+	}
+	if f.lines == "" {
+		all, restricted = append(all, restricted...), nil
 	}
-	cmd.Dir = tmpDir
 
-	var errBuf, outBuf bytes.Buffer
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &errBuf
-	log.Println("running", cmd.Args, "in", tmpDir)
-	if err := cmd.Run(); err != nil {
-		log.Printf("error %v, stderr %s, stdout %s", err, errBuf.String(),
-			outBuf.String())
-		return nil, err
+	if len(all) > 0 {
+		var args []string
+		for _, f := range all {
+			args = append(args, f.Name)
+		}
+		if err := f.run(tmpDir, args); err != nil {
+			if _, ok := err.(*toolError); !ok {
+				return nil, err
+			}
+			// Run the files one by one, to find out which
+			// ones have problems.
+			restricted = append(all, restricted...)
+		}
+	}
+
+	// Line ranges are specific to a file, so these files are
+	// formatted one by one.
+	findings := map[string][]Finding{}
+	for _, file := range restricted {
+		args := []string{file.Name}
+		if f.lines != "" && file.Lines != nil {
+			if args = f.lineArgs(file.Lines); len(args) == 0 {
+				continue
+			}
+			args = append(args, file.Name)
+		}
+		name := filepath.Join(tmpDir, file.Name)
+		if err := ioutil.WriteFile(name, file.Content, 0644); err != nil {
+			return nil, err
+		}
+		err := f.run(tmpDir, args)
+		if te, ok := err.(*toolError); ok {
+			if problems := parseProblems(f.matchers, te.output(), file.Name); len(problems) > 0 {
+				findings[file.Name] = problems
+				err = ioutil.WriteFile(name, file.Content, 0644)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	for _, f := range in {
+		if f.Support {
+			continue
+		}
 		c, err := ioutil.ReadFile(filepath.Join(tmpDir, f.Name))
 		if err != nil {
 			return nil, err
@@ -307,8 +685,65 @@ func (f *toolFormatter) Format(in []File, outSink io.Writer) (out []FormattedFil
 				Name:    f.Name,
 				Content: c,
 			},
+			Findings: findings[f.Name],
 		})
 	}
 
 	return out, nil
 }
+
+func (f *toolFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	args := cfg.Language(lang).Args
+	if len(args) == 0 {
+		return f, nil
+	}
+	out := *f
+	out.args = append(append([]string{}, f.args...), args...)
+	return &out, nil
+}
+
+// lineArgs returns the arguments restricting formatting to the
+// given ranges.
+func (f *toolFormatter) lineArgs(ranges []LineRange) []string {
+	return lineArgs(f.lines, ranges)
+}
+
+// lineArgs formats the ranges with the format string, which takes
+// the start and end (inclusive) of a range. Empty ranges, ie.
+// deletions, select the lines around them.
+func lineArgs(format string, ranges []LineRange) []string {
+	var args []string
+	for _, r := range ranges {
+		start, end := r.Start, r.End-1
+		if r.Start == r.End {
+			start, end = r.Start-1, r.Start
+			if start < 1 {
+				start = 1
+			}
+		}
+		args = append(args, fmt.Sprintf(format, start, end))
+	}
+	return args
+}
+
+// run runs the tool in dir with the given arguments. If the tool
+// fails, the error is a *toolError.
+func (f *toolFormatter) run(dir string, args []string) error {
+	cmd := exec.Command(f.bin, f.args...)
+	cmd.Args = append(cmd.Args, args...)
+	cmd.Dir = dir
+
+	var errBuf, outBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	log.Println("running", cmd.Args, "in", dir)
+	if err := cmd.Run(); err != nil {
+		log.Printf("error %v, stderr %s, stdout %s", err, errBuf.String(),
+			outBuf.String())
+		if _, ok := err.(*exec.ExitError); ok {
+			return &toolError{err: err, stderr: errBuf.String(), stdout: outBuf.String()}
+		}
+		return err
+	}
+	return nil
+}
@@ -32,8 +32,54 @@ def`: "end in '.'",
 		`abc
 
 def`: "",
+		`Revert "` + strings.Repeat("x", 70) + `"
+
+This reverts commit 0123456789abcdef0123456789abcdef01234567.
+
+Reason for revert: broke the build.
+
+Change-Id: I0123456789abcdef0123456789abcdef01234567`: "",
+		`Revert^2 "abc"
+
+This reverts commit 0123456789abcdef0123456789abcdef01234567.
+It was fine after all.`: "",
+		`Revert ""
+
+This reverts commit 0123456789abcdef0123456789abcdef01234567.
+
+Because.`: "quote the original",
+		`Revert "abc"
+
+This reverts commit 0123abc.
+
+Because.`: "40 character",
+		`Revert "abc"
+
+This reverts commit 0123456789abcdef0123456789abcdef01234567.
+
+Change-Id: I0123456789abcdef0123456789abcdef01234567`: "reason",
+		`Revert "abc"
+
+Reason for revert: <INSERT REASONING HERE>
+
+This reverts commit 0123456789abcdef0123456789abcdef01234567.`: "must replace",
+		`Revert "abc"
+
+Because.`: "This reverts commit",
+		"Revert \"abc\"\n \nThis reverts commit 0123456789abcdef0123456789abcdef01234567.\n\nwhy": "",
+		"Revert \"abc\"\n\t\nThis reverts commit 0123abc.\n\nwhy":                                 "40 character",
+		`abc
+
+def
+
+(cherry picked from commit 0123456789abcdef0123456789abcdef01234567)`: "",
+		`abc
+
+def
+
+(cherry picked from commit xyz)`: "40 character",
 	} {
-		got := checkCommitMessage(in)
+		got := checkCommitMessage(in, &CommitMessagePolicy{})
 
 		if want == "" && got != "" {
 			t.Errorf("want empty, got %s", got)
@@ -67,3 +113,11 @@ myfooter: value!`: "",
 		}
 	}
 }
+
+func TestLineArgs(t *testing.T) {
+	f := &toolFormatter{lines: "--lines=%d:%d"}
+	got := strings.Join(f.lineArgs([]LineRange{{1, 3}, {7, 7}, {1, 1}}), " ")
+	if want := "--lines=1:2 --lines=6:7 --lines=1:1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ShellcheckConfig configures the shellcheck stage of the shell
+// checker.
+type ShellcheckConfig struct {
+	// Disable lists codes that are not reported, eg. "SC2086".
+	Disable []string `json:"disable,omitempty" yaml:"disable,omitempty"`
+}
+
+var shellcheckCodeRegex = regexp.MustCompile(`^(SC)?[0-9]+$`)
+
+// shellcheckWikiURL documents a shellcheck code.
+const shellcheckWikiURL = "https://www.shellcheck.net/wiki/%s"
+
+// registerShell adds the shell language, if shfmt or shellcheck is
+// installed, given by path. Shell scripts are selected by
+// extension and by shebang line.
+func registerShell(shfmt, shellcheck string) {
+	if shfmt == "" && shellcheck == "" {
+		return
+	}
+	formatters["shell"] = &FormatterConfig{
+		Regex:   regexp.MustCompile(`\.(sh|bash|ksh)$`),
+		Detect:  []string{DetectedShell},
+		Shebang: true,
+		Query:   "(ext:sh OR ext:bash OR ext:ksh)",
+		Formatter: &pipelineFormatter{stages: []stage{
+			{"shfmt", &shfmtFormatter{shfmt: shfmt}},
+			{"shellcheck", &shellcheckLinter{shellcheck: shellcheck}},
+		}},
+		// shfmt reads .editorconfig, and shellcheck
+		// .shellcheckrc, from the directories of the files.
+		SupportFiles: []string{editorConfigName, ".shellcheckrc"},
+	}
+}
+
+// shfmtFormatter formats with shfmt, which takes its options from
+// .editorconfig, unless LanguageConfig.Args sets any.
+type shfmtFormatter struct {
+	shfmt string
+}
+
+func (f *shfmtFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	if f.shfmt == "" {
+		return nil, nil
+	}
+	return f.tool().configure(cfg, lang)
+}
+
+func (f *shfmtFormatter) tool() *toolFormatter {
+	return &toolFormatter{bin: f.shfmt, args: []string{"-w"}}
+}
+
+func (f *shfmtFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	return f.tool().Format(in, outSink)
+}
+
+// shellcheckLinter reports the diagnostics of shellcheck, with links
+// to the shellcheck wiki. Its "error" and "warning" levels map to
+// SeverityError and SeverityWarning, and the others to
+// SeverityInfo.
+type shellcheckLinter struct {
+	shellcheck string
+	disable    []string
+}
+
+func (f *shellcheckLinter) configure(cfg *Config, lang string) (Formatter, error) {
+	if f.shellcheck == "" {
+		return nil, nil
+	}
+	out := *f
+	if cfg != nil && cfg.Shellcheck != nil {
+		for _, c := range cfg.Shellcheck.Disable {
+			if !shellcheckCodeRegex.MatchString(c) {
+				return nil, fmt.Errorf("shellcheck: disable: invalid code %q", c)
+			}
+		}
+		out.disable = cfg.Shellcheck.Disable
+	}
+	return &out, nil
+}
+
+// linter returns the lintToolFormatter running shellcheck.
+func (f *shellcheckLinter) linter() *lintToolFormatter {
+	args := []string{"--format=json"}
+	if len(f.disable) > 0 {
+		args = append(args, "--exclude="+strings.Join(f.disable, ","))
+	}
+	return &lintToolFormatter{
+		bin:  f.shellcheck,
+		args: args,
+		json: &JSONMapping{
+			File:       "file",
+			Line:       "line",
+			Column:     "column",
+			Severity:   "level",
+			Code:       "code",
+			CodePrefix: "SC",
+			CodeURL:    shellcheckWikiURL,
+		},
+		severities: map[string]string{"style": SeverityInfo},
+	}
+}
+
+func (f *shellcheckLinter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	return f.linter().Format(in, outSink)
+}
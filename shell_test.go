@@ -0,0 +1,99 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestShellcheckLinter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shellcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Reports the --exclude argument as a style comment, and two
+	// fixed problems.
+	bin := filepath.Join(dir, "shellcheck")
+	script := `#!/bin/sh
+excl=none
+for a; do
+  case "$a" in
+    --exclude=*) excl="${a#--exclude=}" ;;
+    -*) ;;
+    *) f="$a" ;;
+  esac
+done
+cat <<EOF2
+[{"file": "$f", "line": 3, "column": 6, "level": "warning", "code": 2086, "message": "Double quote to prevent globbing."},
+ {"file": "$f", "line": 1, "column": 1, "level": "error", "code": 2148, "message": "Add a shebang."},
+ {"file": "$f", "line": 2, "column": 1, "level": "style", "code": 1, "message": "excluded $excl"}]
+EOF2
+exit 1
+`
+	if err := ioutil.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &shellcheckLinter{shellcheck: bin}
+	f, err := l.configure(&Config{Shellcheck: &ShellcheckConfig{Disable: []string{"SC2034", "1090"}}}, "shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Format([]File{{Name: "build-deploy.sh", Content: []byte("echo $x\n")}}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	want := []Finding{
+		{Line: 3, Message: "column 6: SC2086: Double quote to prevent globbing. (https://www.shellcheck.net/wiki/SC2086)", Severity: SeverityWarning},
+		{Line: 1, Message: "column 1: SC2148: Add a shebang. (https://www.shellcheck.net/wiki/SC2148)", Severity: SeverityError},
+		{Line: 2, Message: "column 1: SC1: excluded SC2034,1090 (https://www.shellcheck.net/wiki/SC1)", Severity: SeverityInfo},
+	}
+	if len(out) != 1 || !reflect.DeepEqual(out[0].Findings, want) {
+		t.Errorf("got %v, want %v", out, want)
+	}
+
+	if _, err := l.configure(&Config{Shellcheck: &ShellcheckConfig{Disable: []string{"all"}}}, "shell"); err == nil {
+		t.Errorf("got no error for invalid code")
+	}
+	if f, err := (&shellcheckLinter{}).configure(nil, "shell"); f != nil || err != nil {
+		t.Errorf("without shellcheck: got %v, %v, want no stage", f, err)
+	}
+}
+
+func TestMatchesShebang(t *testing.T) {
+	fc := &FormatterConfig{Regex: regexp.MustCompile(`\.sh$`), Detect: []string{DetectedShell}, Shebang: true}
+	lc := &LanguageConfig{}
+	for _, tc := range []struct {
+		name, content string
+		want          bool
+	}{
+		{"build-deploy.sh", "", true},
+		{"tools/run", "#!/bin/bash\necho\n", true},
+		{"tools/run.py", "#!/usr/bin/env python3\n", false},
+		{"tools/run", "echo\n", false},
+		{"configure", "", true},
+	} {
+		if got := fc.MatchesContent(tc.name, []byte(tc.content), lc); got != tc.want {
+			t.Errorf("MatchesContent(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// SkipFooter is the commit message footer that disables checks for
+// a change. Its value is a comma separated list of languages (or
+// "*" for all), optionally followed by a reason in parentheses, eg.
+//
+//	Lint-Skip: java, bzl (generated by protoc)
+const SkipFooter = "Lint-Skip"
+
+var skipRegex = regexp.MustCompile(`^([^()]*?)\s*(?:\((.*)\))?$`)
+
+// policyLanguages enforce rules on the change itself, which its
+// author must not opt out of, so the SkipFooter does not apply to
+// them, nor to the commitfooter-* languages.
+var policyLanguages = map[string]bool{
+	"commitmsg": true,
+	"filemode":  true,
+	"identity":  true,
+}
+
+// SkipReason returns whether the commit message has a SkipFooter
+// for the given language, and the reason given for it.
+func SkipReason(msg, language string) (reason string, ok bool) {
+	if policyLanguages[language] || strings.HasPrefix(language, "commitfooter-") {
+		return "", false
+	}
+	blocks := strings.Split(strings.TrimSpace(msg), "\n\n")
+	if len(blocks) < 2 {
+		return "", false
+	}
+
+	for _, l := range strings.Split(blocks[len(blocks)-1], "\n") {
+		fields := strings.SplitN(l, ":", 2)
+		if len(fields) < 2 || fields[0] != SkipFooter {
+			continue
+		}
+
+		m := skipRegex.FindStringSubmatch(strings.TrimSpace(fields[1]))
+		if m == nil {
+			continue
+		}
+		for _, lang := range strings.Split(m[1], ",") {
+			lang = strings.TrimSpace(lang)
+			if lang != language && lang != "*" {
+				continue
+			}
+			if m[2] == "" {
+				return "no reason given", true
+			}
+			return m[2], true
+		}
+	}
+	return "", false
+}
+
+// The markers that switch checking off and on inside a file. They
+// are usually put in comments, eg. "// gerrit-linter:off".
+var (
+	markerOff = []byte("gerrit-linter:off")
+	markerOn  = []byte("gerrit-linter:on")
+)
+
+// SuppressedRanges returns the line ranges of content that lie
+// between off and on markers, including the marker lines. A region
+// without an on marker extends to the end of the file.
+func SuppressedRanges(content []byte) []LineRange {
+	var out []LineRange
+	start := 0
+	lines := splitLines(content)
+	for i, l := range lines {
+		if start == 0 && bytes.Contains(l, markerOff) {
+			start = i + 1
+		} else if start > 0 && bytes.Contains(l, markerOn) {
+			out = append(out, LineRange{start, i + 2})
+			start = 0
+		}
+	}
+	if start > 0 {
+		out = append(out, LineRange{start, len(lines) + 1})
+	}
+	return out
+}
+
+// DiffersOutside returns true if b differs from a in lines of a that
+// are not in the given ranges.
+func DiffersOutside(a, b []byte, ranges []LineRange) bool {
+	for _, h := range Diff(a, b) {
+		if !inRanges(h.A, ranges) {
+			return true
+		}
+	}
+	return false
+}
+
+// inRanges returns true if r lies within one of the ranges.
+func inRanges(r LineRange, ranges []LineRange) bool {
+	for _, s := range ranges {
+		if r.Start == r.End {
+			if s.Start < r.Start && r.Start < s.End {
+				return true
+			}
+		} else if s.Start <= r.Start && r.End <= s.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsLine returns true if the line lies in one of the ranges.
+func ContainsLine(ranges []LineRange, line int) bool {
+	for _, r := range ranges {
+		if r.Contains(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSkipReason(t *testing.T) {
+	msg := `Add generated code
+
+Lint-Skip: java, bzl (generated by protoc)
+Lint-Skip: go
+Change-Id: I0123456789abcdef0123456789abcdef01234567
+`
+	for lang, want := range map[string]string{
+		"java": "generated by protoc",
+		"bzl":  "generated by protoc",
+		"go":   "no reason given",
+		"ts":   "",
+	} {
+		got, ok := SkipReason(msg, lang)
+		if ok != (want != "") || got != want {
+			t.Errorf("SkipReason(%q) = %q, %v, want %q", lang, got, ok, want)
+		}
+	}
+
+	if _, ok := SkipReason("Lint-Skip: go", "go"); ok {
+		t.Errorf("footer in subject should be ignored")
+	}
+	if got, ok := SkipReason("abc\n\nLint-Skip: * (vendored)", "go"); !ok || got != "vendored" {
+		t.Errorf("got %q, %v for wildcard", got, ok)
+	}
+	for _, lang := range []string{"identity", "commitmsg", "filemode", "commitfooter-bug"} {
+		if _, ok := SkipReason("abc\n\nLint-Skip: *, "+lang, lang); ok {
+			t.Errorf("policy language %s was skipped", lang)
+		}
+	}
+}
+
+func TestSuppressedRanges(t *testing.T) {
+	content := []byte(`package x
+
+// gerrit-linter:off
+var table = []int{
+   1,2,
+}
+// gerrit-linter:on
+
+var  y int
+`)
+	ranges := SuppressedRanges(content)
+	if want := []LineRange{{3, 8}}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("got %v, want %v", ranges, want)
+	}
+
+	inside := []byte(`package x
+
+// gerrit-linter:off
+var table = []int{
+	1, 2,
+}
+// gerrit-linter:on
+
+var  y int
+`)
+	if DiffersOutside(content, inside, ranges) {
+		t.Errorf("difference inside suppressed region was not ignored")
+	}
+
+	outside := []byte(`package x
+
+// gerrit-linter:off
+var table = []int{
+   1,2,
+}
+// gerrit-linter:on
+
+var y int
+`)
+	if !DiffersOutside(content, outside, ranges) {
+		t.Errorf("difference outside suppressed region was ignored")
+	}
+
+	if got := SuppressedRanges([]byte("a\n// gerrit-linter:off\nb\n")); !reflect.DeepEqual(got, []LineRange{{2, 4}}) {
+		t.Errorf("unterminated region: got %v", got)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// ToolConfig describes a formatting tool that is not built in, so
+// it can be added as a language by RegisterTools.
+type ToolConfig struct {
+	// Command is the tool and its arguments. The names of the
+	// files to format are appended, and they are formatted in
+	// place. If Worker is set, it is a worker command instead.
+	Command []string `json:"command"`
+
+	// Worker is set if Command speaks the worker protocol (see
+	// ServeWorker).
+	Worker bool `json:"worker,omitempty"`
+
+	// Lint is set for linters, which report problems rather than
+	// rewrite files. The file name is appended to Command, and
+	// the output is parsed with ProblemMatchers or JSON.
+	Lint bool `json:"lint,omitempty"`
+
+	// Globs select the files to check (see MatchGlob).
+	Globs []string `json:"globs"`
+
+	// Query filters changes in Gerrit, eg. "ext:foo".
+	Query string `json:"query,omitempty"`
+
+	// Lines is a format string for restricting formatting to a
+	// range of lines, eg. "--lines=%d:%d". See
+	// LanguageConfig.ChangedLines.
+	Lines string `json:"lines,omitempty"`
+
+	// ProblemMatchers are regular expressions that parse the
+	// problems the tool prints when it fails into findings (see
+	// CompileProblemMatcher). If empty, "file:line:col: message"
+	// lines are recognized.
+	ProblemMatchers []string `json:"problem_matchers,omitempty"`
+
+	// JSON parses the output of linters that print JSON.
+	JSON *JSONMapping `json:"json,omitempty"`
+
+	// Severities maps the severity names of a linter to "error",
+	// "warning" or "info", eg. {"style": "info"}.
+	Severities map[string]string `json:"severities,omitempty"`
+}
+
+// formatter returns the formatter for the tool.
+func (tc *ToolConfig) formatter() (Formatter, error) {
+	if len(tc.Command) == 0 {
+		return nil, fmt.Errorf("command is empty")
+	}
+	if len(tc.Globs) == 0 {
+		return nil, fmt.Errorf("globs is empty")
+	}
+	var matchers []*regexp.Regexp
+	for _, m := range tc.ProblemMatchers {
+		re, err := CompileProblemMatcher(m)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, re)
+	}
+	for name, sev := range tc.Severities {
+		if _, ok := severityRanks[sev]; !ok {
+			return nil, fmt.Errorf("severity %q: must be %q, %q or %q, got %q",
+				name, SeverityError, SeverityWarning, SeverityInfo, sev)
+		}
+	}
+	if tc.Lint {
+		if tc.Worker {
+			return nil, fmt.Errorf("linters cannot be workers")
+		}
+		severities := map[string]string{}
+		for name, sev := range tc.Severities {
+			severities[strings.ToLower(name)] = sev
+		}
+		return &lintToolFormatter{
+			bin:        tc.Command[0],
+			args:       tc.Command[1:],
+			matchers:   matchers,
+			json:       tc.JSON,
+			severities: severities,
+		}, nil
+	}
+	if tc.JSON != nil {
+		return nil, fmt.Errorf("json needs lint")
+	}
+	if tc.Worker {
+		return &workerFormatter{
+			command:  tc.Command,
+			lines:    tc.Lines,
+			matchers: matchers,
+		}, nil
+	}
+	return &toolFormatter{
+		bin:      tc.Command[0],
+		args:     tc.Command[1:],
+		lines:    tc.Lines,
+		matchers: matchers,
+	}, nil
+}
+
+// RegisterTools adds languages for the given tools, by language
+// name. Built-in languages cannot be replaced.
+func RegisterTools(tools map[string]*ToolConfig) error {
+	for lang, tc := range tools {
+		if IsSupported(lang) {
+			return fmt.Errorf("tool %q: language already exists", lang)
+		}
+		f, err := tc.formatter()
+		if err != nil {
+			return fmt.Errorf("tool %q: %v", lang, err)
+		}
+		formatters[lang] = &FormatterConfig{
+			Globs:     tc.Globs,
+			Query:     tc.Query,
+			Formatter: f,
+		}
+	}
+	return nil
+}
+
+// LoadTools reads a JSON file mapping language names to
+// ToolConfig, and registers them.
+func LoadTools(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var tools map[string]*ToolConfig
+	if err := json.Unmarshal(content, &tools); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return RegisterTools(tools)
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// The indentation styles of IndentRule.
+const (
+	IndentTab   = "tab"
+	IndentSpace = "space"
+)
+
+// IndentRule requires the indentation of files matching Glob to use
+// the given Style, IndentTab or IndentSpace.
+type IndentRule struct {
+	Glob  string `json:"glob" yaml:"glob"`
+	Style string `json:"style" yaml:"style"`
+}
+
+// WhitespaceConfig configures the whitespace checker.
+type WhitespaceConfig struct {
+	// Indent holds indentation rules. The first matching rule
+	// applies. If nil, defaultIndentRules is used.
+	Indent []IndentRule `json:"indent,omitempty" yaml:"indent,omitempty"`
+}
+
+// defaultIndentRules holds the rules imposed by file formats.
+var defaultIndentRules = []IndentRule{
+	{"*.yaml", IndentSpace},
+	{"*.yml", IndentSpace},
+}
+
+// whitespaceGlobs are the files checked by default. They have no
+// dedicated formatter.
+var whitespaceGlobs = []string{
+	"*.md", "*.yaml", "*.yml", "*.sh", "*.proto", "*.txt",
+}
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// whitespaceFormatter checks whitespace and encoding hygiene without
+// external tools. Everything but indentation and invalid UTF-8 is
+// fixed in the formatted content.
+type whitespaceFormatter struct {
+	indent []IndentRule
+}
+
+func (f *whitespaceFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	out := &whitespaceFormatter{indent: defaultIndentRules}
+	if cfg != nil && cfg.Whitespace != nil && cfg.Whitespace.Indent != nil {
+		out.indent = cfg.Whitespace.Indent
+	}
+	for _, r := range out.indent {
+		if r.Style != IndentTab && r.Style != IndentSpace {
+			return nil, fmt.Errorf("indent style for %q must be %q or %q", r.Glob, IndentTab, IndentSpace)
+		}
+	}
+	return out, nil
+}
+
+func (f *whitespaceFormatter) Format(in []File, outSink io.Writer) (out []FormattedFile, err error) {
+	for _, file := range in {
+		ff := FormattedFile{}
+		ff.Name = file.Name
+		ff.Content, ff.Findings = f.check(file.Name, file.Content)
+		out = append(out, ff)
+	}
+	return out, nil
+}
+
+// indentStyle returns the required indentation for the file, or "".
+func (f *whitespaceFormatter) indentStyle(name string) string {
+	for _, r := range f.indent {
+		if MatchGlob(r.Glob, name) {
+			return r.Style
+		}
+	}
+	return ""
+}
+
+// check returns the fixed content and the findings for a file.
+func (f *whitespaceFormatter) check(name string, content []byte) ([]byte, []Finding) {
+	var findings []Finding
+	if bytes.HasPrefix(content, utf8BOM) {
+		findings = append(findings, Finding{Line: 1, Message: "must not start with a byte order mark"})
+		content = content[len(utf8BOM):]
+	}
+
+	style := f.indentStyle(name)
+	var fixed []byte
+	crlf, crlfFinding := 0, 0
+	lines := splitLines(content)
+	for i, l := range lines {
+		lineNo := i + 1
+		if !utf8.Valid(l) {
+			findings = append(findings, Finding{Line: lineNo, Message: "invalid UTF-8"})
+		}
+
+		eol := []byte{}
+		if bytes.HasSuffix(l, []byte("\r\n")) {
+			if crlf == 0 {
+				crlfFinding = len(findings)
+				findings = append(findings, Finding{Line: lineNo, Message: "must use LF line endings, not CRLF"})
+			}
+			crlf++
+			l = l[:len(l)-2]
+			eol = []byte("\n")
+		} else if bytes.HasSuffix(l, []byte("\n")) {
+			l = l[:len(l)-1]
+			eol = []byte("\n")
+		} else if len(l) > 0 {
+			findings = append(findings, Finding{Line: lineNo, Message: "must end in a newline"})
+			eol = []byte("\n")
+		}
+
+		trimmed := bytes.TrimRight(l, " \t\r")
+		if len(trimmed) < len(l) {
+			findings = append(findings, Finding{Line: lineNo, Message: "trailing whitespace"})
+		}
+
+		indent := trimmed[:len(trimmed)-len(bytes.TrimLeft(trimmed, " \t"))]
+		if style == IndentTab && bytes.IndexByte(indent, ' ') >= 0 {
+			findings = append(findings, Finding{Line: lineNo, Message: "must indent with tabs"})
+		} else if style == IndentSpace && bytes.IndexByte(indent, '\t') >= 0 {
+			findings = append(findings, Finding{Line: lineNo, Message: "must indent with spaces"})
+		}
+
+		fixed = append(fixed, trimmed...)
+		fixed = append(fixed, eol...)
+	}
+	if crlf > 1 {
+		findings[crlfFinding].Message += fmt.Sprintf(" (%d lines)", crlf)
+	}
+	return fixed, findings
+}
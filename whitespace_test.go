@@ -0,0 +1,64 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWhitespace(t *testing.T) {
+	fmtr, err := (&whitespaceFormatter{}).configure(&Config{
+		Whitespace: &WhitespaceConfig{
+			Indent: []IndentRule{{"*.yaml", IndentSpace}, {"Makefile", IndentTab}},
+		},
+	}, "whitespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fmtr.(*whitespaceFormatter)
+
+	for _, tc := range []struct {
+		name, in, want string
+		findings       []Finding
+	}{
+		{"a.md", "# Title\n\ntext\n", "# Title\n\ntext\n", nil},
+		{"a.md", "", "", nil},
+		{"a.md", "text  \nmore\t\n", "text\nmore\n", []Finding{
+			{Line: 1, Message: "trailing whitespace"},
+			{Line: 2, Message: "trailing whitespace"},
+		}},
+		{"a.md", "text", "text\n", []Finding{{Line: 1, Message: "must end in a newline"}}},
+		{"a.md", "a\r\nb\r\n", "a\nb\n", []Finding{{Line: 1, Message: "must use LF line endings, not CRLF (2 lines)"}}},
+		{"a.md", "\xef\xbb\xbfa\n", "a\n", []Finding{{Line: 1, Message: "must not start with a byte order mark"}}},
+		{"a.md", "a\n\xff\n", "a\n\xff\n", []Finding{{Line: 2, Message: "invalid UTF-8"}}},
+		{"a.yaml", "a:\n\tb: 1\n", "a:\n\tb: 1\n", []Finding{{Line: 2, Message: "must indent with spaces"}}},
+		{"Makefile", "all:\n  go build\n", "all:\n  go build\n", []Finding{{Line: 2, Message: "must indent with tabs"}}},
+	} {
+		got, findings := f.check(tc.name, []byte(tc.in))
+		if string(got) != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+		if !reflect.DeepEqual(findings, tc.findings) {
+			t.Errorf("%q: got findings %v, want %v", tc.in, findings, tc.findings)
+		}
+	}
+
+	if _, err := (&whitespaceFormatter{}).configure(&Config{
+		Whitespace: &WhitespaceConfig{Indent: []IndentRule{{"*.c", "tabs"}}},
+	}, "whitespace"); err == nil {
+		t.Errorf("invalid style was accepted")
+	}
+}
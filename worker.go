@@ -0,0 +1,452 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Workers are long-lived formatter processes, which avoid paying
+// for process startup (eg. of a JVM) on every check. They read
+// requests on stdin and write responses on stdout. A request is a
+// sequence of lines
+//
+//	name <file name>
+//	arg <argument>          (repeated)
+//	content <length>
+//
+// followed by <length> bytes of file content. The response is a
+// line "ok <length>", followed by the formatted content, or a line
+// "error <length>", followed by an error message, eg. a syntax
+// error.
+
+// WorkerPoolSize is the maximum number of processes run for each
+// worker command.
+var WorkerPoolSize = 4
+
+// WorkerMaxRequests is the number of requests after which a worker
+// process is restarted, to bound leaks in the formatter.
+var WorkerMaxRequests = 1000
+
+// WorkerTimeout bounds the time a worker process takes to answer a
+// request. A worker that takes longer is killed, so a hung formatter
+// does not block checking.
+var WorkerTimeout = 2 * time.Minute
+
+// errWorkerTimeout is returned for requests that took longer than
+// WorkerTimeout.
+var errWorkerTimeout = errors.New("worker did not answer in time")
+
+// WorkerRequest is a request of the worker protocol.
+type WorkerRequest struct {
+	Name    string
+	Args    []string
+	Content []byte
+}
+
+// workerError is an error reported by a worker for a request, as
+// opposed to a failure of the worker process.
+type workerError struct {
+	msg string
+}
+
+func (e *workerError) Error() string {
+	return e.msg
+}
+
+func writeWorkerRequest(w io.Writer, req *WorkerRequest) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "name %s\n", req.Name)
+	for _, a := range req.Args {
+		fmt.Fprintf(&buf, "arg %s\n", a)
+	}
+	fmt.Fprintf(&buf, "content %d\n", len(req.Content))
+	buf.Write(req.Content)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readWorkerRequest reads a request. It returns io.EOF at the end
+// of the input.
+func readWorkerRequest(r *bufio.Reader) (*WorkerRequest, error) {
+	req := &WorkerRequest{}
+	for first := true; ; first = false {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && first && line == "" {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("malformed request line %q", line)
+		}
+		switch key, val := line[:i], line[i+1:]; key {
+		case "name":
+			req.Name = val
+		case "arg":
+			req.Args = append(req.Args, val)
+		case "content":
+			if req.Content, err = readFrame(r, val); err != nil {
+				return nil, err
+			}
+			return req, nil
+		default:
+			return nil, fmt.Errorf("malformed request line %q", line)
+		}
+	}
+}
+
+// readFrame reads the number of bytes given by the decimal size.
+func readFrame(r *bufio.Reader, size string) ([]byte, error) {
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("malformed length %q", size)
+	}
+	out := make([]byte, n)
+	if _, err := io.ReadFull(r, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func writeWorkerResponse(w io.Writer, content []byte, err error) error {
+	status := "ok"
+	if err != nil {
+		status, content = "error", []byte(err.Error())
+	}
+	if _, err := fmt.Fprintf(w, "%s %d\n", status, len(content)); err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// readWorkerResponse reads a response. Errors reported by the
+// worker are returned as *workerError.
+func readWorkerResponse(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != "ok" && fields[0] != "error" {
+		return nil, fmt.Errorf("malformed response line %q", line)
+	}
+	content, err := readFrame(r, fields[1])
+	if err != nil {
+		return nil, err
+	}
+	if fields[0] == "error" {
+		return nil, &workerError{string(content)}
+	}
+	return content, nil
+}
+
+// ServeWorker implements the worker side of the protocol, calling
+// fn for each request read from r, until the end of r. It helps
+// writing workers in Go.
+func ServeWorker(r io.Reader, w io.Writer, fn func(*WorkerRequest) ([]byte, error)) error {
+	br := bufio.NewReader(r)
+	for {
+		req, err := readWorkerRequest(br)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		content, fnErr := fn(req)
+		if err := writeWorkerResponse(w, content, fnErr); err != nil {
+			return err
+		}
+		if f, ok := w.(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// worker is a running worker process.
+type worker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	served int
+}
+
+func startWorker(command []string) (*worker, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &logWriter{prefix: command[0] + ": "}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	log.Printf("started worker %v, pid %d", command, cmd.Process.Pid)
+	return &worker{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// call sends a request to the worker and reads its response. If
+// that takes longer than WorkerTimeout, the process is killed and
+// errWorkerTimeout returned.
+func (w *worker) call(req *WorkerRequest) ([]byte, error) {
+	w.served++
+	type result struct {
+		content []byte
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if err := writeWorkerRequest(w.stdin, req); err != nil {
+			done <- result{nil, err}
+			return
+		}
+		content, err := readWorkerResponse(w.stdout)
+		done <- result{content, err}
+	}()
+
+	timer := time.NewTimer(WorkerTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.content, r.err
+	case <-timer.C:
+		w.cmd.Process.Kill()
+		return nil, errWorkerTimeout
+	}
+}
+
+func (w *worker) stop() {
+	w.stdin.Close()
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+}
+
+// logWriter logs what is written to it, eg. the stderr of workers.
+type logWriter struct {
+	prefix string
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	log.Printf("%s%s", l.prefix, p)
+	return len(p), nil
+}
+
+// workerPool runs up to WorkerPoolSize processes of a worker
+// command. Processes are started on demand, restarted when they
+// fail, and recycled after WorkerMaxRequests requests.
+type workerPool struct {
+	command []string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	idle    []*worker
+	running int
+}
+
+func newWorkerPool(command []string) *workerPool {
+	p := &workerPool{command: command}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// get returns an idle worker, or starts one.
+func (p *workerPool) get() (*worker, error) {
+	p.mu.Lock()
+	for len(p.idle) == 0 && p.running >= WorkerPoolSize {
+		p.cond.Wait()
+	}
+	if n := len(p.idle); n > 0 {
+		w := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return w, nil
+	}
+	p.running++
+	p.mu.Unlock()
+
+	w, err := startWorker(p.command)
+	if err != nil {
+		p.mu.Lock()
+		p.running--
+		p.cond.Signal()
+		p.mu.Unlock()
+	}
+	return w, err
+}
+
+// put returns a worker to the pool, or stops it if it failed or
+// has served enough requests.
+func (p *workerPool) put(w *worker, failed bool) {
+	recycle := failed || w.served >= WorkerMaxRequests
+	if recycle {
+		w.stop()
+	}
+	p.mu.Lock()
+	if recycle {
+		p.running--
+	} else {
+		p.idle = append(p.idle, w)
+	}
+	p.cond.Signal()
+	p.mu.Unlock()
+}
+
+// call sends a request to a worker. If the worker process fails, the
+// request is retried once on a new process, unless it timed out,
+// which would likely happen again.
+func (p *workerPool) call(req *WorkerRequest) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		w, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		content, err := w.call(req)
+		if _, ok := err.(*workerError); ok || err == nil {
+			p.put(w, false)
+			return content, err
+		}
+		log.Printf("worker %v failed: %v", p.command, err)
+		p.put(w, true)
+		if err == errWorkerTimeout {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// close stops the idle workers.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.running -= len(idle)
+	p.mu.Unlock()
+	for _, w := range idle {
+		w.stop()
+	}
+}
+
+var (
+	workerPoolsMu sync.Mutex
+	workerPools   = map[string]*workerPool{}
+)
+
+// getWorkerPool returns the pool for a command, which is shared by
+// all formatters using it.
+func getWorkerPool(command []string) *workerPool {
+	key := strings.Join(command, "\x00")
+	workerPoolsMu.Lock()
+	defer workerPoolsMu.Unlock()
+	p, ok := workerPools[key]
+	if !ok {
+		p = newWorkerPool(command)
+		workerPools[key] = p
+	}
+	return p
+}
+
+// workerFormatter formats files with a pool of worker processes.
+type workerFormatter struct {
+	command []string
+	args    []string
+
+	// lines and matchers are as for toolFormatter.
+	lines    string
+	matchers []*regexp.Regexp
+}
+
+func (f *workerFormatter) configure(cfg *Config, lang string) (Formatter, error) {
+	args := cfg.Language(lang).Args
+	if len(args) == 0 {
+		return f, nil
+	}
+	out := *f
+	out.args = append(append([]string{}, f.args...), args...)
+	return &out, nil
+}
+
+func (f *workerFormatter) Format(in []File, outSink io.Writer) ([]FormattedFile, error) {
+	pool := getWorkerPool(f.command)
+
+	var files []File
+	for _, file := range in {
+		if !file.Support {
+			files = append(files, file)
+		}
+	}
+	out := make([]FormattedFile, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file File) {
+			defer wg.Done()
+			args := f.args
+			if f.lines != "" && file.Lines != nil {
+				args = append(append([]string{}, args...), lineArgs(f.lines, file.Lines)...)
+				if len(args) == len(f.args) {
+					out[i] = FormattedFile{File: file}
+					return
+				}
+			}
+			content, err := pool.call(&WorkerRequest{
+				Name:    file.Name,
+				Args:    args,
+				Content: file.Content,
+			})
+			if we, ok := err.(*workerError); ok {
+				if problems := parseProblems(f.matchers, we.msg, file.Name); len(problems) > 0 {
+					out[i] = FormattedFile{File: file, Findings: problems}
+					return
+				}
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %v", file.Name, err)
+				return
+			}
+			out[i] = FormattedFile{File: File{Name: file.Name, Content: content}}
+		}(i, file)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
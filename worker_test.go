@@ -0,0 +1,195 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gerritlinter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWorkerEnv = "GERRIT_LINTER_TEST_WORKER"
+
+// The test binary serves as a worker that upper-cases content and
+// appends the arguments. The content "crash" makes it exit, "bad"
+// is an error, "pid" returns its process ID, and "hang" and
+// "partial" never finish their response.
+func init() {
+	if os.Getenv(testWorkerEnv) == "" {
+		return
+	}
+	out := bufio.NewWriter(os.Stdout)
+	ServeWorker(os.Stdin, out, func(req *WorkerRequest) ([]byte, error) {
+		switch string(req.Content) {
+		case "crash":
+			os.Exit(1)
+		case "bad":
+			return nil, fmt.Errorf("%s:1:2: syntax error", req.Name)
+		case "pid":
+			return []byte(fmt.Sprint(os.Getpid())), nil
+		case "hang":
+			select {}
+		case "partial":
+			out.WriteString("ok 10\nabc")
+			out.Flush()
+			select {}
+		}
+		return []byte(strings.ToUpper(string(req.Content)) + strings.Join(req.Args, ",")), nil
+	})
+	os.Exit(0)
+}
+
+func TestWorkerProtocol(t *testing.T) {
+	var in bytes.Buffer
+	for _, req := range []*WorkerRequest{
+		{Name: "a.txt", Args: []string{"-x", "--y=1 2"}, Content: []byte("line\nline\n")},
+		{Name: "b.txt", Content: []byte("bad")},
+	} {
+		if err := writeWorkerRequest(&in, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	err := ServeWorker(&in, &out, func(req *WorkerRequest) ([]byte, error) {
+		if string(req.Content) == "bad" {
+			return nil, fmt.Errorf("bad %s", req.Name)
+		}
+		return []byte(fmt.Sprintf("%s %q %s", req.Name, req.Args, req.Content)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(&out)
+	got, err := readWorkerResponse(r)
+	if want := "a.txt [\"-x\" \"--y=1 2\"] line\nline\n"; err != nil || string(got) != want {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+	_, err = readWorkerResponse(r)
+	if _, ok := err.(*workerError); !ok || err.Error() != "bad b.txt" {
+		t.Errorf("got %v, want workerError", err)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	os.Setenv(testWorkerEnv, "1")
+	defer os.Unsetenv(testWorkerEnv)
+	defer func(size, max int) {
+		WorkerPoolSize, WorkerMaxRequests = size, max
+	}(WorkerPoolSize, WorkerMaxRequests)
+	WorkerPoolSize, WorkerMaxRequests = 2, 3
+
+	p := newWorkerPool([]string{os.Args[0]})
+	defer p.close()
+
+	call := func(content string) (string, error) {
+		out, err := p.call(&WorkerRequest{Name: "a.txt", Content: []byte(content)})
+		return string(out), err
+	}
+
+	pid, err := call("pid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := call("bad"); err == nil || !strings.Contains(err.Error(), "a.txt:1:2: syntax error") {
+		t.Errorf("got %q, %v, want syntax error", got, err)
+	}
+	if got, _ := call("pid"); got != pid {
+		t.Errorf("worker restarted after a request error")
+	}
+	if got, _ := call("pid"); got == pid {
+		t.Errorf("worker not recycled after %d requests", WorkerMaxRequests)
+	}
+
+	// A crash is retried on a new worker, which crashes too.
+	if _, err := call("crash"); err == nil {
+		t.Errorf("crash: got no error")
+	}
+	if got, err := call("abc"); err != nil || got != "ABC" {
+		t.Errorf("after crash: got %q, %v", got, err)
+	}
+	if p.running > WorkerPoolSize {
+		t.Errorf("running %d workers, want at most %d", p.running, WorkerPoolSize)
+	}
+}
+
+func TestWorkerTimeout(t *testing.T) {
+	os.Setenv(testWorkerEnv, "1")
+	defer os.Unsetenv(testWorkerEnv)
+	defer func(d time.Duration) { WorkerTimeout = d }(WorkerTimeout)
+	WorkerTimeout = 200 * time.Millisecond
+
+	p := newWorkerPool([]string{os.Args[0]})
+	defer p.close()
+
+	for _, content := range []string{"hang", "partial"} {
+		_, err := p.call(&WorkerRequest{Name: "a.txt", Content: []byte(content)})
+		if err != errWorkerTimeout {
+			t.Errorf("%s: got %v, want %v", content, err, errWorkerTimeout)
+		}
+		if p.running != 0 || len(p.idle) != 0 {
+			t.Errorf("%s: got %d workers, want the timed out one removed", content, p.running)
+		}
+	}
+
+	// A new worker is started for the next request.
+	if got, err := p.call(&WorkerRequest{Name: "a.txt", Content: []byte("abc")}); err != nil || string(got) != "ABC" {
+		t.Errorf("after timeout: got %q, %v", got, err)
+	}
+}
+
+func TestWorkerFormatter(t *testing.T) {
+	os.Setenv(testWorkerEnv, "1")
+	defer os.Unsetenv(testWorkerEnv)
+
+	f := &workerFormatter{command: []string{os.Args[0], "-test.run=none"}, lines: "--lines=%d:%d"}
+	cfg := &Config{Languages: map[string]*LanguageConfig{"x": {Args: []string{"--aosp"}}}}
+	configured, err := f.configure(cfg, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer getWorkerPool(f.command).close()
+
+	var in []File
+	for i := 0; i < 10; i++ {
+		in = append(in, File{Name: fmt.Sprintf("f%d", i), Content: []byte(fmt.Sprintf("c%d", i))})
+	}
+	in = append(in,
+		File{Name: "lines", Content: []byte("l"), Lines: []LineRange{{Start: 2, End: 4}}},
+		File{Name: "deleted", Content: []byte("d"), Lines: []LineRange{}},
+		File{Name: "cfg", Content: []byte("s"), Support: true})
+	out, err := configured.Format(in, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 12 {
+		t.Fatalf("got %d files, want 12", len(out))
+	}
+	for i := 0; i < 10; i++ {
+		if got, want := string(out[i].Content), fmt.Sprintf("C%d--aosp", i); out[i].Name != in[i].Name || got != want {
+			t.Errorf("%s: got %q, want %q", out[i].Name, got, want)
+		}
+	}
+	if got, want := string(out[10].Content), "L--aosp,--lines=2:3"; got != want {
+		t.Errorf("lines: got %q, want %q", got, want)
+	}
+	if got, want := string(out[11].Content), "d"; got != want {
+		t.Errorf("deleted: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import static java.nio.charset.StandardCharsets.UTF_8;
+
+import com.google.googlejavaformat.java.Main;
+import java.io.BufferedInputStream;
+import java.io.BufferedOutputStream;
+import java.io.ByteArrayInputStream;
+import java.io.ByteArrayOutputStream;
+import java.io.DataInputStream;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.OutputStream;
+import java.io.OutputStreamWriter;
+import java.io.PrintWriter;
+import java.io.StringWriter;
+import java.util.ArrayList;
+import java.util.List;
+
+/**
+ * Runs google-java-format for the requests of the gerrit-linter worker protocol (see worker.go)
+ * read on stdin. Run it with the formatter on the class path:
+ *
+ * <pre>java -cp google-java-format.jar GjfWorker.java</pre>
+ */
+public class GjfWorker {
+  public static void main(String[] argv) throws IOException {
+    DataInputStream in = new DataInputStream(new BufferedInputStream(System.in));
+    OutputStream out = new BufferedOutputStream(System.out);
+    while (true) {
+      List<String> args = new ArrayList<>();
+      byte[] content = null;
+      while (content == null) {
+        String line = readLine(in);
+        if (line == null) {
+          return;
+        }
+        if (line.startsWith("name ")) {
+          // google-java-format does not need the name.
+        } else if (line.startsWith("arg ")) {
+          args.add(line.substring("arg ".length()));
+        } else if (line.startsWith("content ")) {
+          content = new byte[Integer.parseInt(line.substring("content ".length()))];
+          in.readFully(content);
+        } else {
+          throw new IOException("malformed request line: " + line);
+        }
+      }
+      args.add("-");
+
+      ByteArrayOutputStream stdout = new ByteArrayOutputStream();
+      PrintWriter outWriter = new PrintWriter(new OutputStreamWriter(stdout, UTF_8));
+      StringWriter stderr = new StringWriter();
+      PrintWriter errWriter = new PrintWriter(stderr);
+      int code;
+      try {
+        code =
+            new Main(outWriter, errWriter, new ByteArrayInputStream(content))
+                .format(args.toArray(new String[0]));
+      } catch (Exception e) {
+        errWriter.print(e.getMessage());
+        code = 1;
+      }
+      outWriter.flush();
+      errWriter.flush();
+
+      byte[] body = code == 0 ? stdout.toByteArray() : stderr.toString().getBytes(UTF_8);
+      out.write(((code == 0 ? "ok " : "error ") + body.length + "\n").getBytes(UTF_8));
+      out.write(body);
+      out.flush();
+    }
+  }
+
+  /** Returns a line without its newline, or null at the end of the input. */
+  private static String readLine(InputStream in) throws IOException {
+    ByteArrayOutputStream line = new ByteArrayOutputStream();
+    int c;
+    while ((c = in.read()) != '\n') {
+      if (c < 0) {
+        if (line.size() == 0) {
+          return null;
+        }
+        throw new IOException("truncated request");
+      }
+      line.write(c);
+    }
+    return new String(line.toByteArray(), UTF_8);
+  }
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Serves the gerrit-linter worker protocol (see worker.go) on stdin
+// and stdout for a Node based formatter. The formatter is a module
+// exporting
+//
+//   async function format(name, args, content) -> string
+//
+// that throws for errors in the file, eg.
+//
+//   node node-worker.js ./prettier-format.js
+
+'use strict';
+
+const path = require('path');
+
+const formatter = require(path.resolve(process.argv[2]));
+
+let input = Buffer.alloc(0);
+let busy = false;
+
+// parseRequest returns the first request of input and its length,
+// or null if input holds no complete request.
+function parseRequest(buf) {
+  const req = {name: '', args: []};
+  let pos = 0;
+  for (;;) {
+    const nl = buf.indexOf(10, pos);
+    if (nl < 0) {
+      return null;
+    }
+    const line = buf.toString('utf8', pos, nl);
+    pos = nl + 1;
+    const sp = line.indexOf(' ');
+    const key = line.substring(0, sp);
+    const val = line.substring(sp + 1);
+    if (key === 'name') {
+      req.name = val;
+    } else if (key === 'arg') {
+      req.args.push(val);
+    } else if (key === 'content') {
+      const n = parseInt(val, 10);
+      if (buf.length < pos + n) {
+        return null;
+      }
+      req.content = buf.toString('utf8', pos, pos + n);
+      return {req, length: pos + n};
+    } else {
+      throw new Error('malformed request line: ' + line);
+    }
+  }
+}
+
+function respond(status, body) {
+  const data = Buffer.from(body, 'utf8');
+  process.stdout.write(status + ' ' + data.length + '\n');
+  process.stdout.write(data);
+}
+
+async function serve() {
+  if (busy) {
+    return;
+  }
+  busy = true;
+  for (;;) {
+    const parsed = parseRequest(input);
+    if (parsed === null) {
+      break;
+    }
+    input = input.slice(parsed.length);
+    const {req} = parsed;
+    try {
+      respond('ok', await formatter.format(req.name, req.args, req.content));
+    } catch (e) {
+      respond('error', String(e.message || e));
+    }
+  }
+  busy = false;
+}
+
+process.stdin.on('data', (chunk) => {
+  input = Buffer.concat([input, chunk]);
+  serve();
+});
@@ -0,0 +1,79 @@
+// Copyright 2020 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Formats files with prettier, for node-worker.js. The prettier
+// package directory is the argument after this module, eg.
+//
+//   node node-worker.js prettier-format.js /usr/lib/node_modules/prettier
+//
+// The arguments of a request are "--options=<JSON>" with the
+// resolved .prettierrc options, followed by options in the style of
+// the prettier command line, eg. "--print-width=100" or
+// "--no-semi".
+
+'use strict';
+
+const path = require('path');
+
+const prettier = require(path.resolve(process.argv[3] || 'prettier'));
+
+// parseArgs returns the prettier options for the request arguments.
+// The Go side only passes formatting options; options that load
+// modules are dropped here too.
+function parseArgs(args) {
+  let opts = {};
+  for (const a of args) {
+    const eq = a.indexOf('=');
+    let key = a.replace(/^--?/, '');
+    let val = true;
+    if (eq >= 0) {
+      key = a.substring(0, eq).replace(/^--?/, '');
+      val = a.substring(eq + 1);
+    }
+    if (key === 'options') {
+      opts = Object.assign(opts, JSON.parse(val));
+      continue;
+    }
+    if (eq < 0 && key.startsWith('no-')) {
+      key = key.substring(3);
+      val = false;
+    } else if (val === 'true' || val === 'false') {
+      val = val === 'true';
+    } else if (typeof val === 'string' && /^[0-9]+$/.test(val)) {
+      val = parseInt(val, 10);
+    }
+    opts[key.replace(/-([a-z])/g, (m, c) => c.toUpperCase())] = val;
+  }
+  delete opts.plugin;
+  delete opts.plugins;
+  delete opts.pluginSearchDirs;
+  return opts;
+}
+
+exports.format = async function(name, args, content) {
+  const opts = parseArgs(args);
+  opts.filepath = name;
+  try {
+    return await prettier.format(content, opts);
+  } catch (e) {
+    // Report syntax errors as "file:line:col: message", so they
+    // become findings.
+    const msg = String(e.message || e).split('\n')[0];
+    if (e.loc && e.loc.start) {
+      throw new Error(
+          `${name}:${e.loc.start.line}:${e.loc.start.column}: ${msg}`);
+    }
+    throw e;
+  }
+};